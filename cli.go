@@ -0,0 +1,603 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/internal/excel"
+	"github.com/kremec/edugroup/types"
+)
+
+// Exit codes returned in non-interactive mode.
+const (
+	exitOK         = 0
+	exitFailure    = 1
+	exitUsage      = 2
+	exitValidation = 3
+	exitInfeasible = 4
+	exitIO         = 5
+)
+
+const (
+	modeSubject    = "subject"
+	modeCount      = "count"
+	modePreference = "preference"
+)
+
+type cliOptions struct {
+	inputFile  string
+	outputFile string
+	csvFiles   excel.CSVFiles
+	// historyPath is read into history before every grouping.
+	historyPath string
+	// inputLayoutPath is read into inputLayout before every grouping, and
+	// sheetNames from -sheet are added to it.
+	inputLayoutPath string
+	inputLayout     excel.InputLayout
+	sheetNames      excel.SheetNames
+	// addr is where the serve command listens.
+	addr string
+	// layout arranges the groups in Excel output files.
+	layout     excel.Layout
+	history    *grouping.History
+	mode       string
+	numGroups  int
+	seed       int64
+	timeBudget time.Duration
+	balance    bool
+	minSize    int
+	maxSize    int
+	// alternatives is the number of distinct groupings to make, at least
+	// minDifference students apart.
+	alternatives  int
+	minDifference int
+	weights       grouping.Weights
+	debug         bool
+}
+
+// commandAnalyze only runs the feasibility analysis of the input.
+const commandAnalyze = "analyze"
+
+func parseFlags(args []string) cliOptions {
+	var opts cliOptions
+
+	flag.StringVar(&opts.inputFile, "in", "", "input Excel, CSV or JSON `file`; enables non-interactive mode")
+	flag.StringVar(&opts.outputFile, "out", "", "output Excel, CSV or JSON `file` (non-interactive mode, optional)")
+	flag.StringVar(&opts.csvFiles.Exclusions, "exclusions", "", "CSV `file` with exclusion groups, one per column (CSV input only)")
+	flag.StringVar(&opts.csvFiles.Inclusions, "inclusions", "", "CSV `file` with inclusion groups, one per column (CSV input only)")
+	flag.StringVar(&opts.csvFiles.SoftExclusions, "soft-exclusions", "", "CSV `file` with \"prefer apart\" groups, one per column (CSV input only)")
+	flag.StringVar(&opts.csvFiles.SoftInclusions, "soft-inclusions", "", "CSV `file` with \"prefer together\" groups, one per column (CSV input only)")
+	flag.StringVar(&opts.csvFiles.FixedAssignments, "fixed-assignments", "", "CSV `file` with a student and the group they must be in per row (CSV input only)")
+	flag.StringVar(&opts.csvFiles.NamedGroups, "named-groups", "", "CSV `file` with a group name and its capacity per row, to use instead of -groups (CSV input only)")
+	flag.StringVar(&opts.csvFiles.Rankings, "rankings", "", "CSV `file` with a student and the named groups they would like to be in, best first, per row (CSV input only)")
+	flag.StringVar(&opts.csvFiles.Nominations, "nominations", "", "CSV `file` with a student and the classmates they would like to work with, one student per column (CSV input only)")
+	flag.Func("layout", "`layout` of the groups in Excel output files: \"rows\", \"columns\" or \"table\" (default \"rows\")", func(value string) error {
+		var err error
+		opts.layout, err = excel.ParseLayout(value)
+		return err
+	})
+	flag.StringVar(&opts.inputLayoutPath, "input-layout", "", "JSON or YAML `file` telling where the data starts on the sheets of Excel input files and how names are split")
+	flag.Func("sheet", "recognize an Excel input sheet by another name, as `role=name` with role one of students, exclusions, inclusions, soft-exclusions, soft-inclusions, fixed-assignments, named-groups, rankings and nominations (repeatable)", func(value string) error {
+		role, name, ok := strings.Cut(value, "=")
+		if !ok {
+			return errors.New("expected role=name")
+		}
+		return opts.sheetNames.Add(role, strings.TrimSpace(name))
+	})
+	flag.StringVar(&opts.mode, "mode", "", "grouping `mode`: \"subject\", \"count\" or \"preference\" (default: \"count\" if -groups or -named-groups is set, else \"subject\")")
+	flag.IntVar(&opts.numGroups, "groups", 0, "number of groups in count mode (default: one per named group of the input, as always in preference mode)")
+	flag.Int64Var(&opts.seed, "seed", 0, "random seed; the same input and seed always give the same groups (0 picks a new seed)")
+	flag.StringVar(&opts.historyPath, "history", "", "`folder` of earlier output files (or a single one); students grouped together before are kept apart where possible")
+	flag.BoolVar(&opts.balance, "balance", false, "keep group sizes within one student of each other where the constraints allow it")
+	flag.IntVar(&opts.minSize, "min-size", 0, "minimum number of students in a group (0 for no limit)")
+	flag.IntVar(&opts.maxSize, "max-size", 0, "maximum number of students in a group (0 for no limit)")
+	flag.IntVar(&opts.alternatives, "alternatives", 1, "number of distinct groupings to make, each on a sheet of its own in Excel output files")
+	flag.IntVar(&opts.minDifference, "min-difference", 0, "number of students every alternative places differently from the others, at least (0 for any difference)")
+	opts.weights = grouping.DefaultWeights()
+	flag.Float64Var(&opts.weights.PreferApart, "weight-apart", opts.weights.PreferApart, "penalty for two \"prefer apart\" students in the same group")
+	flag.Float64Var(&opts.weights.PreferTogether, "weight-together", opts.weights.PreferTogether, "penalty for two \"prefer together\" students in different groups")
+	flag.Float64Var(&opts.weights.Rankings, "weight-rankings", opts.weights.Rankings, "penalty for every place a student's group is below their first choice in preference mode")
+	flag.Float64Var(&opts.weights.Nominations, "weight-nominations", opts.weights.Nominations, "penalty for every student placed with none of the classmates they nominated")
+	flag.Float64Var(&opts.weights.AttributeBalance, "weight-attributes", opts.weights.AttributeBalance, "penalty for spreading attribute values such as gender unevenly over the groups")
+	flag.Float64Var(&opts.weights.RepeatPartners, "weight-repeats", opts.weights.RepeatPartners, "penalty for two students grouped together again, for every earlier grouping they shared")
+	flag.DurationVar(&opts.timeBudget, "time-budget", grouping.DefaultTimeBudget, "time limit for the searches on top of their work limit; when it runs out first, the seed may not repeat the groups")
+	flag.StringVar(&opts.addr, "addr", "localhost:8080", "`address` the serve command listens on, e.g. \":8080\" for all network interfaces")
+	flag.BoolVar(&opts.debug, "debug", false, "print debug output")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [analyze | serve] [flags]\n\n", os.Args[0])
+		fmt.Fprintln(out, "Without -in the program runs interactively. The analyze command only checks")
+		fmt.Fprintln(out, "whether the input can be grouped and how many groups are needed at least.")
+		fmt.Fprintln(out, "The serve command groups workbooks and JSON problems sent over HTTP to -addr.")
+		fmt.Fprintln(out)
+		flag.PrintDefaults()
+	}
+	flag.CommandLine.Parse(args)
+
+	return opts
+}
+
+// runBatch groups the students from opts.inputFile without any prompts or
+// dialogs and returns the process exit code.
+func runBatch(opts cliOptions) int {
+	data, mode, code := readBatchInput(&opts, true)
+	if data == nil {
+		return code
+	}
+
+	var err error
+	opts.history, err = loadHistory(opts.historyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	groupingMode := parseMode(mode)
+	results, err := solveGroups(data, groupingMode, opts.numGroups, opts)
+	if err != nil {
+		return solveError(err)
+	}
+
+	// The details are those of the best option
+	for i, result := range results {
+		if len(results) > 1 {
+			fmt.Printf("Option %d (penalty %g):\n", i+1, result.Penalty)
+		}
+		printGroups(result.Groups, result.GroupNames)
+	}
+	result := results[0]
+	fmt.Println("Seed:", result.Seed)
+	printViolations(result.Violations)
+	printAttributes(result.Attributes, result.GroupNames)
+	printUnmetWishes(result.UnmetWishes)
+	printRepeatPartners(result.RepeatPartners)
+	printSatisfaction(result.Satisfaction)
+
+	if opts.outputFile != "" {
+		switch {
+		case hasExtension(opts.outputFile, ".csv"):
+			err = excel.ExportToCSV(result.Groups, result.GroupNames, opts.outputFile)
+		case hasExtension(opts.outputFile, ".json"):
+			err = excel.ExportToJSON(results, opts.outputFile)
+		default:
+			err = excel.ExportToExcel(exportOptions(results), exportSummary(result, data, groupingMode, opts.numGroups, opts.inputFile), opts.layout, opts.outputFile)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitIO
+		}
+		fmt.Println("Groups exported to", opts.outputFile)
+	}
+
+	return exitOK
+}
+
+// runAnalyze prints the feasibility analysis of opts.inputFile and returns
+// the process exit code: exitInfeasible when no grouping can exist.
+func runAnalyze(opts cliOptions) int {
+	if opts.inputFile == "" {
+		return usageError("analyze requires -in")
+	}
+	if opts.outputFile != "" {
+		return usageError("-out cannot be used with analyze")
+	}
+
+	data, mode, code := readBatchInput(&opts, false)
+	if data == nil {
+		return code
+	}
+
+	groupingMode := parseMode(mode)
+	analysis, err := grouping.Analyze(context.Background(), data, groupingOptions(groupingMode, opts.numGroups, opts))
+	if err != nil {
+		return solveError(err)
+	}
+
+	fmt.Printf("Students: %d in %d placement units\n", analysis.Students, analysis.Units)
+	if len(analysis.Clique) > 1 {
+		fmt.Printf("Largest set of units that must all be in different groups (%d): %s\n", len(analysis.Clique), formatUnits(analysis.Clique))
+		if !analysis.CliqueComplete {
+			fmt.Println("The search for this set ran out of time; a larger one may exist.")
+		}
+	}
+	fmt.Println("Minimum number of groups:", analysis.MinGroups)
+	if analysis.MaxGroups > 0 {
+		fmt.Println("Maximum number of groups:", analysis.MaxGroups)
+	}
+
+	if len(analysis.Problems) > 0 {
+		fmt.Println("No grouping is possible:")
+		for _, problem := range analysis.Problems {
+			fmt.Println("-", problem)
+		}
+		return exitInfeasible
+	}
+	fmt.Println("No obstacles found.")
+
+	return exitOK
+}
+
+// readBatchInput reads the input file named in opts and checks the flags
+// against it. It returns the data and mode, or nil data and the exit code.
+// Count mode needs -groups only when requireGroups is set and the input
+// names no groups.
+func readBatchInput(opts *cliOptions, requireGroups bool) (*types.GroupingData, string, int) {
+	// A JSON problem brings its own options, which flags on the command line
+	// override.
+	var data *types.GroupingData
+	if hasExtension(opts.inputFile, ".json") {
+		problem, problemOpts, err := excel.ReadJSONProblem(opts.inputFile)
+		if err != nil {
+			return nil, "", inputError(err)
+		}
+		if opts.mode != "" && opts.mode != problemOpts.Mode.String() {
+			return nil, "", usageError("-mode %s does not match %s, which describes a %s mode problem", opts.mode, opts.inputFile, problemOpts.Mode)
+		}
+		applyProblemOptions(opts, problemOpts)
+		data = problem
+	}
+
+	// A named groups file only has a use in count and preference mode.
+	mode := opts.mode
+	if mode == "" {
+		mode = modeSubject
+		if opts.numGroups > 0 || opts.csvFiles.NamedGroups != "" {
+			mode = modeCount
+		}
+	}
+
+	switch {
+	case mode != modeSubject && mode != modeCount && mode != modePreference:
+		return nil, "", usageError("unknown mode %q, expected %q, %q or %q", mode, modeSubject, modeCount, modePreference)
+	case mode != modeSubject && opts.numGroups < 0:
+		return nil, "", usageError("-groups must be a positive number")
+	case mode == modeSubject && opts.numGroups != 0:
+		return nil, "", usageError("-groups cannot be used in subject mode")
+	case opts.alternatives < 1:
+		return nil, "", usageError("-alternatives must be a positive number")
+	case opts.minDifference < 0:
+		return nil, "", usageError("-min-difference must not be negative")
+	case opts.alternatives > 1 && hasExtension(opts.outputFile, ".csv"):
+		return nil, "", usageError("-alternatives requires an Excel or JSON output file")
+	case flag.NArg() > 0:
+		return nil, "", usageError("unexpected arguments: %s", strings.Join(flag.Args(), " "))
+	case !hasExtension(opts.inputFile, ".csv") && opts.csvFiles != (excel.CSVFiles{}):
+		return nil, "", usageError("-exclusions, -inclusions, -soft-exclusions, -soft-inclusions, -fixed-assignments, -named-groups, -rankings and -nominations require a CSV input file")
+	}
+
+	if DEBUG {
+		fmt.Println("Input file:", opts.inputFile)
+	}
+
+	var err error
+	opts.inputLayout, err = loadInputLayout(opts.inputLayoutPath, opts.sheetNames)
+	if err != nil {
+		return nil, "", inputError(err)
+	}
+	csvFiles := opts.csvFiles
+	csvFiles.Roster = opts.inputFile
+	switch {
+	case data != nil:
+	case hasExtension(opts.inputFile, ".csv") && mode == modeSubject:
+		data, err = excel.ReadCSVSubjectGroups(csvFiles)
+	case hasExtension(opts.inputFile, ".csv"):
+		data, err = excel.ReadCSVNumGroups(csvFiles)
+	case mode == modeSubject:
+		data, err = excel.ReadExcelSubjectGroups(opts.inputFile, opts.inputLayout)
+	default:
+		data, err = excel.ReadExcelNumGroups(opts.inputFile, opts.inputLayout)
+	}
+	if err != nil {
+		return nil, "", inputError(err)
+	}
+	if hasExtension(opts.inputFile, ".xlsx") {
+		printSheetRoles(opts.inputFile, opts.inputLayout.Sheets)
+	}
+
+	// Named groups stand in for -groups, and preference mode ranks them.
+	switch named := len(data.NamedGroups); {
+	case mode == modeSubject:
+	case named == 0 && mode == modePreference:
+		return nil, "", inputError(&excel.InputError{Issues: []string{fmt.Sprintf("preference mode requires named groups in %s for the students to rank", opts.inputFile)}})
+	case named > 0 && opts.numGroups == 0:
+		opts.numGroups = named
+	case named > 0 && opts.numGroups != named:
+		return nil, "", usageError("-groups %d does not match the %d named groups of %s", opts.numGroups, named, opts.inputFile)
+	case named == 0 && opts.numGroups == 0 && requireGroups:
+		return nil, "", usageError("count mode requires -groups with a positive number, or named groups in the input")
+	}
+
+	return data, mode, exitOK
+}
+
+// parseMode returns the grouping mode of a -mode value checked by
+// readBatchInput.
+func parseMode(mode string) grouping.Mode {
+	switch mode {
+	case modeCount:
+		return grouping.ByCount
+	case modePreference:
+		return grouping.ByPreference
+	default:
+		return grouping.BySubject
+	}
+}
+
+// printSheetRoles tells which sheet of the workbook was read for which role.
+func printSheetRoles(filename string, names excel.SheetNames) {
+	roles, err := excel.ReadSheetRoles(filename, names)
+	if err != nil || len(roles) == 0 {
+		return
+	}
+
+	found := "by position, as no sheet has a known name"
+	if roles[0].ByName {
+		found = "by name"
+	}
+	sheets := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if role.Sheet != "" {
+			sheets = append(sheets, fmt.Sprintf("%s %q", role.Role, role.Sheet))
+		}
+	}
+	fmt.Printf("Sheets found %s: %s\n", found, strings.Join(sheets, ", "))
+}
+
+func printGroups(groups [][]string, names []string) {
+	for i, group := range groups {
+		fmt.Printf("%s: %s\n", groupLabel(names, i), strings.Join(group, ", "))
+	}
+}
+
+// groupLabel returns the name of the group with the given index, or its
+// number without names.
+func groupLabel(names []string, groupIndex int) string {
+	if groupIndex < len(names) {
+		return names[groupIndex]
+	}
+
+	return fmt.Sprintf("Group %d", groupIndex+1)
+}
+
+func printViolations(violations []grouping.Violation) {
+	if len(violations) == 0 {
+		return
+	}
+
+	fmt.Printf("%d preferences could not be met:\n", len(violations))
+	for _, violation := range violations {
+		fmt.Printf("- %s: %s\n", violation.Kind, violation)
+	}
+}
+
+// printUnmetWishes lists the students placed with none of the classmates
+// they nominated.
+func printUnmetWishes(unmet []grouping.UnmetWish) {
+	if len(unmet) == 0 {
+		return
+	}
+
+	fmt.Printf("%d students are with none of the classmates they nominated:\n", len(unmet))
+	for _, wish := range unmet {
+		fmt.Printf("- %s\n", wish)
+	}
+}
+
+func printAttributes(distribution []grouping.AttributeDistribution, names []string) {
+	for _, attribute := range distribution {
+		fmt.Printf("%s per group:\n", attribute.Attribute)
+		for groupIndex, counts := range attribute.Counts {
+			parts := make([]string, len(counts))
+			for valueIndex, count := range counts {
+				parts[valueIndex] = fmt.Sprintf("%s %d", attribute.Values[valueIndex], count)
+			}
+			fmt.Printf("- %s: %s\n", groupLabel(names, groupIndex), strings.Join(parts, ", "))
+		}
+	}
+}
+
+func printRepeatPartners(repeats []grouping.RepeatPartners) {
+	if len(repeats) == 0 {
+		return
+	}
+
+	fmt.Printf("%d students were grouped with someone again:\n", len(repeats))
+	for _, repeat := range repeats {
+		fmt.Printf("- %s: %d (%s)\n", repeat.Student, len(repeat.Partners), strings.Join(repeat.Partners, ", "))
+	}
+}
+
+// printSatisfaction tells how many students got which of their choices in
+// preference mode.
+func printSatisfaction(satisfaction *grouping.Satisfaction) {
+	if satisfaction == nil {
+		return
+	}
+
+	fmt.Println("Choices met:")
+	for place, count := range satisfaction.Choices {
+		fmt.Printf("- %s: %d\n", grouping.ChoiceLabel(place+1), count)
+	}
+	fmt.Printf("- %s: %d\n", grouping.ChoiceLabel(0), satisfaction.Unranked)
+}
+
+// formatUnits lists placement units, with inclusion groups in brackets.
+func formatUnits(units [][]string) string {
+	names := make([]string, len(units))
+	for i, unit := range units {
+		names[i] = strings.Join(unit, ", ")
+		if len(unit) > 1 {
+			names[i] = "(" + names[i] + ")"
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// loadInputLayout reads the input layout at path, or returns the default
+// layout when path is empty, and adds sheetNames to it.
+func loadInputLayout(path string, sheetNames excel.SheetNames) (excel.InputLayout, error) {
+	layout := excel.DefaultInputLayout()
+	if path != "" {
+		var err error
+		layout, err = excel.ReadInputLayout(path)
+		if err != nil {
+			return excel.InputLayout{}, err
+		}
+	}
+	layout.Sheets.Extend(sheetNames)
+
+	return layout, nil
+}
+
+// loadHistory reads the earlier groupings at path, or returns nil when no
+// history is used.
+func loadHistory(path string) (*grouping.History, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	history, err := excel.ReadHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	if DEBUG {
+		fmt.Printf("Read %d earlier groupings from %s\n", history.Rounds(), path)
+	}
+
+	return history, nil
+}
+
+// exportOptions collects the groups and penalty of every result for the
+// output workbook.
+func exportOptions(results []*grouping.Result) []excel.Option {
+	options := make([]excel.Option, len(results))
+	for i, result := range results {
+		options[i] = excel.Option{Groups: result.Groups, Penalty: result.Penalty}
+	}
+
+	return options
+}
+
+// exportSummary collects the result details written to the output workbook.
+func exportSummary(result *grouping.Result, data *types.GroupingData, mode grouping.Mode, numGroups int, inputFile string) excel.Summary {
+	// Named groups are not counted when asked for
+	if mode != grouping.BySubject && numGroups == 0 {
+		numGroups = len(result.Groups)
+	}
+	summary := excel.Summary{
+		Mode:           mode,
+		NumGroups:      numGroups,
+		Seed:           result.Seed,
+		GroupNames:     result.GroupNames,
+		InputFile:      filepath.Base(inputFile),
+		Created:        time.Now(),
+		Data:           data,
+		Attributes:     result.Attributes,
+		RepeatPartners: result.RepeatPartners,
+		Satisfaction:   result.Satisfaction,
+		UnmetWishes:    result.UnmetWishes,
+	}
+	for _, violation := range result.Violations {
+		summary.ViolatedPreferences = append(summary.ViolatedPreferences, fmt.Sprintf("%s: %s", violation.Kind, violation))
+	}
+
+	return summary
+}
+
+// applyProblemOptions copies the options of a JSON problem into opts, except
+// for those given on the command line.
+func applyProblemOptions(opts *cliOptions, problem grouping.Options) {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	opts.mode = problem.Mode.String()
+	if !set["groups"] {
+		opts.numGroups = problem.NumGroups
+	}
+	if !set["seed"] {
+		opts.seed = problem.Seed
+	}
+	if !set["balance"] {
+		opts.balance = problem.Balance
+	}
+	if !set["min-size"] {
+		opts.minSize = problem.MinSize
+	}
+	if !set["max-size"] {
+		opts.maxSize = problem.MaxSize
+	}
+	if !set["alternatives"] && problem.Alternatives > 0 {
+		opts.alternatives = problem.Alternatives
+	}
+	if !set["min-difference"] {
+		opts.minDifference = problem.MinDifference
+	}
+	if !set["time-budget"] && problem.TimeBudget != 0 {
+		opts.timeBudget = problem.TimeBudget
+	}
+	if problem.Weights != nil {
+		if !set["weight-apart"] {
+			opts.weights.PreferApart = problem.Weights.PreferApart
+		}
+		if !set["weight-together"] {
+			opts.weights.PreferTogether = problem.Weights.PreferTogether
+		}
+		if !set["weight-rankings"] {
+			opts.weights.Rankings = problem.Weights.Rankings
+		}
+		if !set["weight-nominations"] {
+			opts.weights.Nominations = problem.Weights.Nominations
+		}
+		if !set["weight-attributes"] {
+			opts.weights.AttributeBalance = problem.Weights.AttributeBalance
+		}
+		if !set["weight-repeats"] {
+			opts.weights.RepeatPartners = problem.Weights.RepeatPartners
+		}
+	}
+}
+
+// hasExtension reports whether filename ends in ext, ignoring letter case.
+func hasExtension(filename string, ext string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ext)
+}
+
+// inputError reports an error reading the input and returns the exit code.
+func inputError(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	if errors.Is(err, excel.ErrInvalidInput) {
+		return exitValidation
+	}
+	return exitIO
+}
+
+// solveError reports an error of the grouping engine and returns the exit
+// code: exitValidation for data or options it rejects, exitInfeasible for
+// input that cannot be grouped.
+func solveError(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	var infeasible *grouping.InfeasibleError
+	switch {
+	case errors.Is(err, grouping.ErrInvalidInput):
+		return exitValidation
+	case errors.As(err, &infeasible):
+		return exitInfeasible
+	}
+	return exitFailure
+}
+
+func usageError(format string, args ...any) int {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	flag.Usage()
+	return exitUsage
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/internal/excel"
+)
+
+func TestSolveErrorExitCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("reading options: %w", grouping.ErrInvalidInput), exitValidation},
+		{&grouping.InfeasibleError{Msg: "no grouping exists"}, exitInfeasible},
+		{errors.New("out of memory"), exitFailure},
+	}
+
+	for _, test := range tests {
+		if code := solveError(test.err); code != test.want {
+			t.Errorf("solveError(%v) = %d, want %d", test.err, code, test.want)
+		}
+	}
+
+	if code := inputError(&excel.InputError{Issues: []string{"student \"Ana\" is duplicated"}}); code != exitValidation {
+		t.Errorf("invalid input exits with %d, want %d", code, exitValidation)
+	}
+	if code := inputError(errors.New("permission denied")); code != exitIO {
+		t.Errorf("an unreadable file exits with %d, want %d", code, exitIO)
+	}
+}
+
+func TestRunBatchExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	problems := map[string]string{
+		"groups.json":     `{"students": ["Ana", "Bor", "Cene", "Dana"], "options": {"numGroups": 2}}`,
+		"invalid.json":    `{"students": ["Ana", "Ana"], "options": {"numGroups": 2}}`,
+		"infeasible.json": `{"students": ["Ana", "Bor", "Cene"], "exclusions": [["Ana", "Bor", "Cene"]], "options": {"numGroups": 2}}`,
+	}
+	for name, problem := range problems {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(problem), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"groups.json", exitOK},
+		{"invalid.json", exitValidation},
+		{"infeasible.json", exitInfeasible},
+		{"missing.json", exitIO},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			opts := cliOptions{
+				inputFile:    filepath.Join(dir, test.input),
+				outputFile:   filepath.Join(t.TempDir(), "groups.csv"),
+				weights:      grouping.DefaultWeights(),
+				timeBudget:   grouping.DefaultTimeBudget,
+				alternatives: 1,
+			}
+			if code := runBatch(opts); code != test.want {
+				t.Errorf("exit code %d, want %d", code, test.want)
+			}
+			if _, err := os.Stat(opts.outputFile); (err == nil) != (test.want == exitOK) {
+				t.Errorf("output written: %t, want %t", err == nil, test.want == exitOK)
+			}
+		})
+	}
+}
+
+func TestCSVNamedGroupsSelectCountMode(t *testing.T) {
+	dir := t.TempDir()
+	opts := cliOptions{inputFile: filepath.Join(dir, "students.csv"), alternatives: 1}
+	opts.csvFiles.NamedGroups = filepath.Join(dir, "groups.csv")
+	if err := os.WriteFile(opts.inputFile, []byte("Ana\nBor\nCene\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(opts.csvFiles.NamedGroups, []byte("Lab\nLibrary\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, mode, code := readBatchInput(&opts, true)
+	if code != exitOK {
+		t.Fatalf("exit code %d, want %d", code, exitOK)
+	}
+	if mode != modeCount || opts.numGroups != 2 {
+		t.Errorf("mode %q with %d groups, want %q with 2", mode, opts.numGroups, modeCount)
+	}
+	if len(data.Students) != 3 {
+		t.Errorf("students %v", data.Students)
+	}
+}
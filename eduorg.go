@@ -2,19 +2,17 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
-	"math/rand"
 	"os"
-	"os/exec"
-	"slices"
-	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/kremec/edugroup/grouping"
 	"github.com/kremec/edugroup/internal/dialogs"
 	"github.com/kremec/edugroup/internal/excel"
-	"github.com/kremec/edugroup/internal/types"
+	"github.com/kremec/edugroup/types"
 )
 
 const (
@@ -27,25 +25,43 @@ var DEBUG bool = false
 func main() {
 	// Parse command line arguments
 	args := os.Args[1:]
-	if len(args) > 0 {
-		if args[0] == "-debug" {
-			DEBUG = true
-			args = args[1:]
-		}
+	command := ""
+	if len(args) > 0 && (args[0] == commandAnalyze || args[0] == commandServe) {
+		command, args = args[0], args[1:]
+	}
+	opts := parseFlags(args)
+	DEBUG = opts.debug
+
+	switch command {
+	case commandAnalyze:
+		os.Exit(runAnalyze(opts))
+	case commandServe:
+		os.Exit(runServe(opts))
+	}
+	if opts.inputFile != "" {
+		os.Exit(runBatch(opts))
 	}
 
+	runInteractive(opts, bufio.NewReader(os.Stdin))
+}
+
+// runInteractive asks for the grouping mode, the seed and the files in a loop
+// until the user exits. All answers on the console are read from console,
+// so that none of them are buffered away when standard input is piped.
+func runInteractive(opts cliOptions, console *bufio.Reader) {
 	fmt.Println("Welcome to EduGroup!")
 	for {
 		// Give user instructions
 		fmt.Println("Available grouping modes:")
 		fmt.Printf("0) %s\n", "Group students by subject groups")
 		fmt.Printf("n) %s\n", "Group students into 'n' groups")
+		fmt.Printf("g) %s\n", "Group students into the named groups of the workbook")
+		fmt.Printf("p) %s\n", "Assign students to the named groups by their ranked choices")
 		fmt.Println("<ENTER>) Exit")
 		fmt.Print("Enter your choice: ")
 
 		// Read user input
-		reader := bufio.NewReader(os.Stdin)
-		input, _ := reader.ReadString('\n')
+		input, _ := console.ReadString('\n')
 		input = strings.TrimSpace(input) // Remove any leading/trailing whitespace
 
 		// Exit if user presses ENTER
@@ -54,15 +70,37 @@ func main() {
 			return
 		}
 
-		groupMode, err := strconv.Atoi(input)
-		if err != nil || groupMode < 0 {
-			fmt.Printf("%sInvalid input. Please enter 0, a positive integer, or press ENTER to exit.%s\n", redText, resetText)
-			restartProgramDelimiter()
-			continue
+		// Named groups take their number from the workbook, and so do the
+		// groups students rank
+		ranked := strings.EqualFold(input, "p")
+		namedGroups := strings.EqualFold(input, "g") || ranked
+		groupMode := 0
+		var err error
+		if !namedGroups {
+			groupMode, err = strconv.Atoi(input)
+			if err != nil || groupMode < 0 {
+				fmt.Printf("%sInvalid input. Please enter 0, a positive integer, g, p, or press ENTER to exit.%s\n", redText, resetText)
+				restartProgramDelimiter()
+				continue
+			}
+		}
+
+		// Read random seed, ENTER keeps the one given on the command line
+		runOpts := opts
+		fmt.Print("Enter random seed to repeat an earlier grouping (<ENTER> for a new one): ")
+		input, _ = console.ReadString('\n')
+		input = strings.TrimSpace(input)
+		if input != "" {
+			runOpts.seed, err = strconv.ParseInt(input, 10, 64)
+			if err != nil || runOpts.seed <= 0 {
+				fmt.Printf("%sInvalid seed. Please enter a positive integer or press ENTER.%s\n", redText, resetText)
+				restartProgramDelimiter()
+				continue
+			}
 		}
 
 		// Open Excel file
-		inputFile, err := dialogs.OpenExcelFile()
+		inputFile, err := dialogs.OpenExcelFile(console)
 		if err != nil {
 			dialogs.ShowErrorDialog(err)
 			restartProgramDelimiter()
@@ -72,18 +110,46 @@ func main() {
 			fmt.Println("Input file:", inputFile)
 		}
 
-		var groups [][]string
-		if groupMode == 0 {
+		// Read earlier groupings again every round, as the last output may
+		// have been saved to the history folder
+		runOpts.history, err = loadHistory(opts.historyPath)
+		if err != nil {
+			dialogs.ShowErrorDialog(err)
+			restartProgramDelimiter()
+			continue
+		}
+
+		// The input layout is read again every round too, so that it can be
+		// corrected without restarting
+		runOpts.inputLayout, err = loadInputLayout(opts.inputLayoutPath, opts.sheetNames)
+		if err != nil {
+			dialogs.ShowErrorDialog(err)
+			restartProgramDelimiter()
+			continue
+		}
+
+		mode := grouping.BySubject
+		switch {
+		case ranked:
+			mode = grouping.ByPreference
+		case groupMode > 0 || namedGroups:
+			mode = grouping.ByCount
+		}
+
+		var data *types.GroupingData
+		var results []*grouping.Result
+		if mode == grouping.BySubject {
 			// Read Excel file
-			data, err := excel.ReadExcelSubjectGroups(inputFile)
+			data, err = excel.ReadExcelSubjectGroups(inputFile, runOpts.inputLayout)
 			if err != nil {
 				dialogs.ShowErrorDialog(err)
 				restartProgramDelimiter()
 				continue
 			}
+			printSheetRoles(inputFile, runOpts.inputLayout.Sheets)
 
 			// Create student groups based on subjects and exclusions
-			groups, err = createSubjectGroups(data)
+			results, err = solveGroups(data, grouping.BySubject, 0, runOpts)
 			if err != nil {
 				dialogs.ShowErrorDialog(err)
 				restartProgramDelimiter()
@@ -92,15 +158,22 @@ func main() {
 		} else {
 			numGroups := groupMode
 			// Read Excel file
-			data, err := excel.ReadExcelNumGroups(inputFile)
+			data, err = excel.ReadExcelNumGroups(inputFile, runOpts.inputLayout)
 			if err != nil {
 				dialogs.ShowErrorDialog(err)
 				restartProgramDelimiter()
 				continue
 			}
+			printSheetRoles(inputFile, runOpts.inputLayout.Sheets)
+			if namedGroups && len(data.NamedGroups) == 0 {
+				dialogs.ShowErrorDialog(errors.New("the workbook has no named groups sheet; add one or enter the number of groups"))
+				restartProgramDelimiter()
+				continue
+			}
 
-			// Create student groups based on number of groups
-			groups, err = createNumGroups(data, numGroups)
+			// Create student groups based on number of groups, or on the
+			// choices of the students
+			results, err = solveGroups(data, mode, numGroups, runOpts)
 			if err != nil {
 				dialogs.ShowErrorDialog(err)
 				restartProgramDelimiter()
@@ -108,10 +181,22 @@ func main() {
 			}
 		}
 
-		fmt.Printf("\nGrouping successful - %d groups created.\n", len(groups))
+		result := results[0]
+		fmt.Printf("\nGrouping successful - %d groups created (seed %d).\n", len(result.Groups), result.Seed)
+		if len(results) > 1 {
+			fmt.Printf("%d options were made, best first:\n", len(results))
+			for i, option := range results {
+				fmt.Printf("- Option %d: penalty %g\n", i+1, option.Penalty)
+			}
+		}
+		printViolations(result.Violations)
+		printAttributes(result.Attributes, result.GroupNames)
+		printUnmetWishes(result.UnmetWishes)
+		printRepeatPartners(result.RepeatPartners)
+		printSatisfaction(result.Satisfaction)
 
 		// Export the groups to Excel file
-		outputFile, err := dialogs.SaveExcelFile(inputFile)
+		outputFile, err := dialogs.SaveExcelFile(console, inputFile)
 		if err != nil {
 			dialogs.ShowErrorDialog(err)
 			restartProgramDelimiter()
@@ -120,14 +205,18 @@ func main() {
 		if DEBUG {
 			fmt.Println("Output file:", outputFile)
 		}
-		excel.ExportToExcel(groups, outputFile)
+		err = excel.ExportToExcel(exportOptions(results), exportSummary(result, data, mode, groupMode, inputFile), runOpts.layout, outputFile)
+		if err != nil {
+			dialogs.ShowErrorDialog(err)
+			restartProgramDelimiter()
+			continue
+		}
 
 		fmt.Println("Groups exported to", outputFile)
 
-		// Open Excel file
-		cmd := exec.Command("cmd", "/c", "start", outputFile)
-		err = cmd.Start()
-		if err != nil {
+		// Open Excel file, unless there is no desktop to show it on
+		err = dialogs.OpenFile(outputFile)
+		if err != nil && !errors.Is(err, dialogs.ErrNoGUI) {
 			dialogs.ShowErrorDialog(err)
 			restartProgramDelimiter()
 			continue
@@ -142,257 +231,63 @@ func restartProgramDelimiter() {
 	fmt.Println()
 }
 
-// CreateGroups creates student groups based on the subjects and exclusions data.
-func createSubjectGroups(data *types.GroupingData) ([][]string, error) {
-	groups := [][]string{}
-	exclusionLookup := buildExclusionLookup(data.Exclusions)
-
-	// Map students to corresponding subjects
-	studentSubject := make(map[string]string)
-	for subject, students := range data.SubjectStudents {
-		for _, student := range students {
-			if student != "" {
-				studentSubject[student] = subject
-			}
-		}
-	}
+// solveGroups runs the grouping engine with the options shared by the
+// interactive and non-interactive modes, after telling the user how many
+// groups are needed at least. It returns one result, or the alternatives
+// asked for, best first.
+func solveGroups(data *types.GroupingData, mode grouping.Mode, numGroups int, cli cliOptions) ([]*grouping.Result, error) {
+	opts := groupingOptions(mode, numGroups, cli)
 
-	if err := validateSubjectInclusions(data.Inclusions, studentSubject, exclusionLookup); err != nil {
+	analysis, err := grouping.Analyze(context.Background(), data, opts)
+	if err != nil {
 		return nil, err
 	}
-
-	allStudents := flattenSubjectStudentsBySubject(data.SubjectStudents)
-	units := buildAssignmentUnits(allStudents, data.Inclusions, exclusionLookup)
-
-	canAddUnitToGroup := func(unit []string, group []string) bool {
-		for _, student := range unit {
-			for _, studentInGroup := range group {
-				// Dissallow students from the same subject
-				if studentSubject[studentInGroup] == studentSubject[student] {
-					return false
-				}
-
-				if studentsConflict(student, studentInGroup, exclusionLookup) {
-					return false
-				}
-			}
-		}
-		return true
-	}
-
-	processUnit := func(unit []string) {
-		// Add student to existing groups if possible
-		for groupIndex, group := range groups {
-			if canAddUnitToGroup(unit, group) {
-				if DEBUG {
-					fmt.Printf("Adding %v to group %s\n", unit, group)
-				}
-				groups[groupIndex] = append(group, unit...)
-				return
-			}
-		}
-
-		// Else create a new group
-		if DEBUG {
-			fmt.Printf("Creating new group for %v\n", unit)
-		}
-		groups = append(groups, slices.Clone(unit))
-	}
-
-	// Process inclusion groups and constrained students first
-	for _, unit := range units {
-		if DEBUG {
-			fmt.Printf("Processing: %v\n", unit)
+	// Solve stops before searching when the check found problems, and
+	// reports them with the constraints and cells involved.
+	if len(analysis.Problems) == 0 {
+		needed := fmt.Sprintf("at least %d groups are needed", analysis.MinGroups)
+		if analysis.MinGroups == 1 {
+			needed = "at least 1 group is needed"
 		}
-		processUnit(unit)
-		if DEBUG {
-			fmt.Println("Current groups:", groups)
-			fmt.Println()
+		if len(analysis.Clique) > 1 {
+			fmt.Printf("Feasibility check: %s (%s must all be in different groups).\n", needed, formatUnits(analysis.Clique))
+		} else {
+			fmt.Printf("Feasibility check: %s.\n", needed)
 		}
 	}
+	opts.Analysis = analysis
 
-	if DEBUG {
-		fmt.Println("Final groups:", groups)
-	}
-
-	return groups, nil
-}
-
-// CreateGroups creates student groups based on the number of groups.
-func createNumGroups(data *types.GroupingData, numGroups int) ([][]string, error) {
-	groups := make([][]string, numGroups)
-	exclusionLookup := buildExclusionLookup(data.Exclusions)
-
-	if err := validateInclusionsAgainstExclusions(data.Inclusions, exclusionLookup); err != nil {
+	results, err := grouping.SolveAlternatives(context.Background(), data, opts)
+	if err != nil {
 		return nil, err
 	}
 
-	units := buildAssignmentUnits(data.Students, data.Inclusions, exclusionLookup)
-
-	canAddUnitToGroup := func(unit []string, group []string) bool {
-		for _, student := range unit {
-			for _, studentInGroup := range group {
-				if studentsConflict(student, studentInGroup, exclusionLookup) {
-					return false
-				}
-			}
-		}
-		return true
-	}
-
-	processUnit := func(unit []string) error {
-
-		// Add student to existing groups if possible
-		sort.Slice(groups, func(i, j int) bool {
-			return len(groups[i]) < len(groups[j])
-		})
-		for groupIndex, group := range groups {
-			if canAddUnitToGroup(unit, group) {
-				if DEBUG {
-					fmt.Printf("Adding %v to group %s\n", unit, group)
-				}
-				groups[groupIndex] = append(group, unit...)
-				return nil
-			}
-		}
-		return errors.New("exception and inclusion constraints cannot be met for this number of groups")
+	for _, note := range results[0].Diagnostics.Notes {
+		fmt.Println("Note:", note)
 	}
 
-	// Process inclusion groups and constrained students first
-	for _, unit := range units {
-		if DEBUG {
-			fmt.Printf("Processing: %v\n", unit)
-		}
-		if err := processUnit(unit); err != nil {
-			return nil, err
-		}
-		if DEBUG {
-			fmt.Println("Current groups:", groups)
-			fmt.Println()
-		}
-	}
-
-	if DEBUG {
-		fmt.Println("Final groups:", groups)
-	}
-
-	return groups, nil
-}
-
-func buildExclusionLookup(exclusions [][]string) map[string]map[string]struct{} {
-	lookup := make(map[string]map[string]struct{})
-
-	for _, exclusionGroup := range exclusions {
-		for _, student := range exclusionGroup {
-			if lookup[student] == nil {
-				lookup[student] = make(map[string]struct{})
-			}
-
-			for _, otherStudent := range exclusionGroup {
-				if otherStudent == student {
-					continue
-				}
-				lookup[student][otherStudent] = struct{}{}
-			}
-		}
-	}
-
-	return lookup
+	return results, nil
 }
 
-func validateSubjectInclusions(inclusions [][]string, studentSubject map[string]string, exclusionLookup map[string]map[string]struct{}) error {
-	if err := validateInclusionsAgainstExclusions(inclusions, exclusionLookup); err != nil {
-		return err
-	}
-
-	for _, inclusionGroup := range inclusions {
-		seenSubjects := make(map[string]string)
-		for _, student := range inclusionGroup {
-			subject := studentSubject[student]
-			if firstStudent, exists := seenSubjects[subject]; exists {
-				return fmt.Errorf("students %q and %q are required to be together but both belong to subject %q", firstStudent, student, subject)
-			}
-			seenSubjects[subject] = student
-		}
+// groupingOptions converts the command-line options for the grouping engine.
+func groupingOptions(mode grouping.Mode, numGroups int, cli cliOptions) grouping.Options {
+	opts := grouping.Options{
+		Mode:       mode,
+		NumGroups:  numGroups,
+		Seed:       cli.seed,
+		Balance:    cli.balance,
+		MinSize:    cli.minSize,
+		MaxSize:    cli.maxSize,
+		Weights:    &cli.weights,
+		TimeBudget: cli.timeBudget,
+		History:    cli.history,
+
+		Alternatives:  cli.alternatives,
+		MinDifference: cli.minDifference,
 	}
-
-	return nil
-}
-
-func validateInclusionsAgainstExclusions(inclusions [][]string, exclusionLookup map[string]map[string]struct{}) error {
-	for _, inclusionGroup := range inclusions {
-		for i := 0; i < len(inclusionGroup); i++ {
-			for j := i + 1; j < len(inclusionGroup); j++ {
-				if studentsConflict(inclusionGroup[i], inclusionGroup[j], exclusionLookup) {
-					return fmt.Errorf("students %q and %q are required to be together but are also listed in an exclusion group", inclusionGroup[i], inclusionGroup[j])
-				}
-			}
-		}
-	}
-
-	return nil
-}
-
-func buildAssignmentUnits(students []string, inclusions [][]string, exclusionLookup map[string]map[string]struct{}) [][]string {
-	units := make([][]string, 0, len(students))
-	includedStudents := make(map[string]struct{}, len(students))
-
-	for _, inclusionGroup := range inclusions {
-		unit := slices.Clone(inclusionGroup)
-		units = append(units, unit)
-		for _, student := range inclusionGroup {
-			includedStudents[student] = struct{}{}
-		}
-	}
-
-	for _, student := range students {
-		if _, exists := includedStudents[student]; exists {
-			continue
-		}
-		units = append(units, []string{student})
-	}
-
-	rand.Shuffle(len(units), func(i, j int) {
-		units[i], units[j] = units[j], units[i]
-	})
-
-	sort.SliceStable(units, func(i, j int) bool {
-		iHasConstraints := unitHasExclusions(units[i], exclusionLookup)
-		jHasConstraints := unitHasExclusions(units[j], exclusionLookup)
-		if iHasConstraints != jHasConstraints {
-			return iHasConstraints
-		}
-
-		if len(units[i]) != len(units[j]) {
-			return len(units[i]) > len(units[j])
-		}
-
-		return false
-	})
-
-	return units
-}
-
-func unitHasExclusions(unit []string, exclusionLookup map[string]map[string]struct{}) bool {
-	for _, student := range unit {
-		if len(exclusionLookup[student]) > 0 {
-			return true
-		}
-	}
-
-	return false
-}
-
-func studentsConflict(student string, otherStudent string, exclusionLookup map[string]map[string]struct{}) bool {
-	_, exists := exclusionLookup[student][otherStudent]
-	return exists
-}
-
-func flattenSubjectStudentsBySubject(subjectStudents map[string][]string) []string {
-	students := make([]string, 0)
-	for _, subjectGroup := range subjectStudents {
-		students = append(students, subjectGroup...)
+	if DEBUG {
+		opts.Log = os.Stdout
 	}
 
-	return students
+	return opts
 }
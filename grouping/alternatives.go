@@ -0,0 +1,162 @@
+package grouping
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kremec/edugroup/types"
+)
+
+const (
+	// alternativeAttempts is the number of times SolveAlternatives tries to
+	// find each alternative before it gives up.
+	alternativeAttempts = 8
+	// alternativeWeight is the first penalty for a pair of students grouped
+	// together in an earlier alternative. It is small, so that keeping the
+	// pairs apart only decides between groupings that are as good otherwise,
+	// and doubles with every alternative that was not different enough.
+	alternativeWeight = 0.05
+)
+
+// SolveAlternatives groups the students in data like Solve, but returns up
+// to opts.Alternatives groupings that each place at least opts.MinDifference
+// students differently from every other one, best first by Penalty. Every
+// alternative is improved like a single grouping, while pairs of students
+// grouped together in the alternatives found before are kept apart where
+// possible. All attempts share opts.TimeBudget, so that asking for more
+// alternatives does not take longer.
+//
+// All results carry the seed of the first grouping, which repeats the whole
+// run: every alternative depends on the ones found before it, so none of them
+// can be repeated on its own. When fewer alternatives differ enough, or the
+// time budget ran out before all attempts were made, a note in the
+// diagnostics of the best result says so.
+func SolveAlternatives(ctx context.Context, data *types.GroupingData, opts Options) ([]*Result, error) {
+	budget := opts.TimeBudget
+	if budget == 0 {
+		budget = DefaultTimeBudget
+	}
+	deadline := time.Now().Add(budget)
+
+	// The data is analyzed once for all alternatives.
+	if opts.Analysis == nil && opts.Alternatives > 1 && opts.Mode.countsGroups() {
+		analysis, err := Analyze(ctx, data, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.Analysis = analysis
+	}
+
+	first, err := solveApartFrom(ctx, data, opts, nil, 0, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*Result{first}
+	earlier := NewHistory()
+	earlier.AddRound(first.Groups)
+	minDifference := max(opts.MinDifference, 1)
+	weight := alternativeWeight
+	timedOut := false
+	for attempt := 1; len(results) < opts.Alternatives && attempt <= opts.Alternatives*alternativeAttempts; attempt++ {
+		if ctx.Err() != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			timedOut = true
+			break
+		}
+
+		alternativeOpts := opts
+		alternativeOpts.Seed = first.Seed + int64(attempt)
+		result, err := solveApartFrom(ctx, data, alternativeOpts, earlier, weight, deadline)
+		if err != nil {
+			return nil, err
+		}
+
+		// Too close to an alternative found before, so try again with
+		// more weight on keeping the earlier pairs apart.
+		if difference := closestDifference(result, results); difference < minDifference {
+			weight *= 2
+			continue
+		}
+
+		result.Seed = first.Seed
+		results = append(results, result)
+		earlier.AddRound(result.Groups)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Penalty < results[j].Penalty
+	})
+	switch {
+	case len(results) < opts.Alternatives && timedOut:
+		results[0].Diagnostics.Notes = append(results[0].Diagnostics.Notes, fmt.Sprintf("Only %d of %d groupings were found before the time budget ran out, so the same seed may give other groupings on another run.", len(results), opts.Alternatives))
+	case len(results) < opts.Alternatives:
+		results[0].Diagnostics.Notes = append(results[0].Diagnostics.Notes, fmt.Sprintf("Only %d of %d groupings could be found that place at least %s differently from each other.", len(results), opts.Alternatives, pluralize(minDifference, "student")))
+	}
+
+	return results, nil
+}
+
+// closestDifference returns the fewest students result places differently
+// from any of others.
+func closestDifference(result *Result, others []*Result) int {
+	closest := -1
+	for _, other := range others {
+		difference := placementDifference(result.Groups, other.Groups, result.GroupNames != nil)
+		if closest < 0 || difference < closest {
+			closest = difference
+		}
+	}
+
+	return closest
+}
+
+// placementDifference counts the students placed differently in a and b:
+// in a group of another name when the groups are named, and with other
+// groupmates otherwise, as the order of unnamed groups does not matter.
+func placementDifference(a [][]string, b [][]string, named bool) int {
+	groupOfB := groupIndex(b)
+	difference := 0
+	for groupIndex, group := range a {
+		if named {
+			for _, student := range group {
+				if otherGroup, placed := groupOfB[student]; !placed || otherGroup != groupIndex {
+					difference++
+				}
+			}
+			continue
+		}
+
+		// Students have the same groupmates exactly when their whole group
+		// is a group of b too.
+		if !isGroupOf(group, b, groupOfB) {
+			difference += len(group)
+		}
+	}
+
+	return difference
+}
+
+// isGroupOf reports whether groups holds a group with exactly the students
+// of group; groupOf maps the students of groups to their group.
+func isGroupOf(group []string, groups [][]string, groupOf map[string]int) bool {
+	if len(group) == 0 {
+		return true
+	}
+
+	other, placed := groupOf[group[0]]
+	if !placed || len(groups[other]) != len(group) {
+		return false
+	}
+	for _, student := range group {
+		if otherGroup, placed := groupOf[student]; !placed || otherGroup != other {
+			return false
+		}
+	}
+
+	return true
+}
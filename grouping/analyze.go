@@ -0,0 +1,233 @@
+package grouping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kremec/edugroup/types"
+)
+
+// Analysis holds bounds on the number of groups that follow from the data
+// alone, found without searching for a grouping.
+type Analysis struct {
+	Mode     Mode
+	Students int
+	// Units is the number of placement units: inclusion groups plus students
+	// that are not part of any inclusion group.
+	Units int
+	// Clique is the largest set of units found that all exclude each other
+	// (in BySubject mode, students of the same subject exclude each other
+	// too). Every one of them needs its own group.
+	Clique [][]string
+	// CliqueComplete is false when the clique search ran out of nodes or
+	// time, so a larger clique may exist.
+	CliqueComplete bool
+	// MinGroups is the smallest number of groups that could possibly work.
+	MinGroups int
+	// MaxGroups is the largest number of groups MinSize allows, or 0 when
+	// there is no minimum size.
+	MaxGroups int
+	// Problems lists the reasons why no grouping can exist for the options.
+	// An empty list does not guarantee that a grouping exists.
+	Problems []string
+}
+
+// Analyze checks data and opts for obvious obstacles, such as more mutually
+// excluded students than groups, and derives the minimum number of groups.
+// It runs within a limit on the nodes searched and opts.TimeBudget, and
+// does not use the random seed.
+func Analyze(ctx context.Context, data *types.GroupingData, opts Options) (*Analysis, error) {
+	if data == nil {
+		return nil, fmt.Errorf("grouping: no data")
+	}
+	opts, err := withNamedGroups(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSolver(ctx, data, opts, 0).analyze(), nil
+}
+
+// newSolver prepares the state of a single Solve or Analyze call.
+func newSolver(ctx context.Context, data *types.GroupingData, opts Options, seed int64) *solver {
+	budget := opts.TimeBudget
+	if budget == 0 {
+		budget = DefaultTimeBudget
+	}
+
+	return &solver{
+		ctx:             ctx,
+		data:            data,
+		opts:            opts,
+		rng:             rand.New(rand.NewSource(seed)),
+		log:             opts.Log,
+		exclusionLookup: buildExclusionLookup(data.Exclusions),
+		deadline:        time.Now().Add(budget),
+	}
+}
+
+func (s *solver) analyze() *Analysis {
+	analysis := &Analysis{Mode: s.opts.Mode, Students: s.studentCount()}
+
+	students := s.data.Students
+	studentSubject := make(map[string]string)
+	if s.opts.Mode == BySubject {
+		students = flattenSubjectStudentsBySubject(s.data)
+		for subject, subjectStudents := range s.data.SubjectStudents {
+			for _, student := range subjectStudents {
+				studentSubject[student] = subject
+			}
+		}
+	}
+	conflict := func(student string, otherStudent string) bool {
+		if subject, exists := studentSubject[student]; exists && subject == studentSubject[otherStudent] {
+			return true
+		}
+		return studentsConflict(student, otherStudent, s.exclusionLookup)
+	}
+
+	// Units are built in input order, so the analysis does not depend on the seed.
+	units := make([][]string, 0, len(students))
+	included := make(map[string]bool)
+	for _, inclusionGroup := range s.data.Inclusions {
+		units = append(units, inclusionGroup)
+		for i, student := range inclusionGroup {
+			included[student] = true
+			for _, otherStudent := range inclusionGroup[i+1:] {
+				if conflict(student, otherStudent) {
+					analysis.Problems = append(analysis.Problems, fmt.Sprintf("students %q and %q are required to be together but cannot be in the same group", student, otherStudent))
+				}
+			}
+		}
+	}
+	for _, student := range students {
+		if !included[student] {
+			units = append(units, []string{student})
+		}
+	}
+	analysis.Units = len(units)
+
+	for _, validate := range []func() error{s.validateNamedGroups, s.validateRankings, func() error { return s.validateAssignments(studentSubject) }} {
+		var infeasibleErr *InfeasibleError
+		if err := validate(); errors.As(err, &infeasibleErr) {
+			analysis.Problems = append(analysis.Problems, infeasibleErr.Msg)
+		} else if err != nil {
+			analysis.Problems = append(analysis.Problems, err.Error())
+		}
+	}
+
+	conflicts := unitConflicts(units, func(unit []string, otherUnit []string) bool {
+		for _, student := range unit {
+			for _, otherStudent := range otherUnit {
+				if conflict(student, otherStudent) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+
+	clique, complete := s.largestClique(conflicts)
+	analysis.CliqueComplete = complete
+	for _, unit := range clique {
+		analysis.Clique = append(analysis.Clique, units[unit])
+	}
+
+	analysis.MinGroups = max(len(clique), s.lastPinnedGroup()+1, 1)
+	if s.opts.MaxSize > 0 {
+		analysis.MinGroups = max(analysis.MinGroups, (analysis.Students+s.opts.MaxSize-1)/s.opts.MaxSize)
+	}
+	if s.opts.MinSize > 0 {
+		analysis.MaxGroups = analysis.Students / s.opts.MinSize
+	}
+
+	switch {
+	case s.opts.Mode.countsGroups() && s.opts.NumGroups > 0 && s.opts.NumGroups < len(clique):
+		analysis.Problems = append(analysis.Problems, fmt.Sprintf("the number of groups, %d, is too small: %s must all be in different groups", s.opts.NumGroups, describeUnits(analysis.Clique)))
+	case s.opts.Mode.countsGroups() && s.opts.NumGroups > 0 && s.opts.NumGroups < analysis.MinGroups:
+		analysis.Problems = append(analysis.Problems, fmt.Sprintf("the number of groups, %d, is too small: %d students do not fit into groups of at most %d", s.opts.NumGroups, analysis.Students, s.opts.MaxSize))
+	case s.opts.Mode.countsGroups() && analysis.MaxGroups > 0 && s.opts.NumGroups > analysis.MaxGroups:
+		analysis.Problems = append(analysis.Problems, fmt.Sprintf("the number of groups, %d, is too large: %s cannot fill groups of at least %d", s.opts.NumGroups, pluralize(analysis.Students, "student"), s.opts.MinSize))
+	case analysis.MaxGroups > 0 && analysis.MinGroups > analysis.MaxGroups:
+		analysis.Problems = append(analysis.Problems, fmt.Sprintf("at least %s are needed, but groups of at least %s allow at most %d", pluralize(analysis.MinGroups, "group"), pluralize(s.opts.MinSize, "student"), analysis.MaxGroups))
+	}
+
+	return analysis
+}
+
+// largestClique returns the largest set of units that all conflict with each
+// other, found by branch and bound over the units in order of falling degree.
+// The second result is false when the node limit or the time budget ran out
+// before the search could prove that no larger clique exists.
+func (s *solver) largestClique(conflicts [][]int) ([]int, bool) {
+	adjacent := make([]map[int]bool, len(conflicts))
+	order := make([]int, len(conflicts))
+	for unit, others := range conflicts {
+		adjacent[unit] = make(map[int]bool, len(others))
+		for _, other := range others {
+			adjacent[unit][other] = true
+		}
+		order[unit] = unit
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(conflicts[order[i]]) > len(conflicts[order[j]])
+	})
+
+	best := make([]int, 0)
+	nodes := 0
+	complete := true
+	var extend func(clique []int, candidates []int)
+	extend = func(clique []int, candidates []int) {
+		if len(clique) > len(best) {
+			best = slices.Clone(clique)
+		}
+
+		nodes++
+		switch {
+		case nodes > searchNodeLimit:
+			complete = false
+		case nodes%1024 != 0:
+		case s.ctx.Err() != nil:
+			complete = false
+		case time.Now().After(s.deadline):
+			complete = false
+			s.timedOut = true
+		}
+
+		for i, unit := range candidates {
+			if !complete || len(clique)+len(candidates)-i <= len(best) {
+				return
+			}
+
+			next := make([]int, 0, len(candidates)-i)
+			for _, other := range candidates[i+1:] {
+				if adjacent[unit][other] {
+					next = append(next, other)
+				}
+			}
+			extend(append(clique, unit), next)
+		}
+	}
+	extend(nil, order)
+
+	return best, complete
+}
+
+// describeUnits lists units for messages, with inclusion groups in brackets.
+func describeUnits(units [][]string) string {
+	names := make([]string, len(units))
+	for i, unit := range units {
+		names[i] = quoteNames(unit)
+		if len(unit) > 1 {
+			names[i] = "(" + names[i] + ")"
+		}
+	}
+
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,43 @@
+package grouping
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+func TestAnalyzeGroupCountProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		data *types.GroupingData
+		opts Options
+		want string
+	}{
+		{
+			name: "too many groups",
+			data: &types.GroupingData{Students: []string{"A"}},
+			opts: Options{Mode: ByCount, NumGroups: 2, MinSize: 1},
+			want: "the number of groups, 2, is too large: 1 student cannot fill groups of at least 1",
+		},
+		{
+			name: "too few students per group",
+			data: &types.GroupingData{Subjects: []string{"Math"}, SubjectStudents: map[string][]string{"Math": {"A", "B", "C"}}},
+			opts: Options{Mode: BySubject, MinSize: 2},
+			want: "at least 3 groups are needed, but groups of at least 2 students allow at most 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			analysis, err := Analyze(context.Background(), test.data, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(analysis.Problems, test.want) {
+				t.Errorf("problems %q do not contain %q", analysis.Problems, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package grouping
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// unitPins returns the group every unit is fixed to by the assignments of its
+// students, or -1 for units without any. The second result is false when
+// students of the same unit are fixed to different groups.
+func unitPins(units [][]string, assignments map[string]int) ([]int, bool) {
+	pins := make([]int, len(units))
+	consistent := true
+	for unitIndex, unit := range units {
+		pins[unitIndex] = -1
+		for _, student := range unit {
+			group, fixed := assignments[student]
+			if !fixed {
+				continue
+			}
+			if pins[unitIndex] >= 0 && pins[unitIndex] != group {
+				consistent = false
+			}
+			pins[unitIndex] = group
+		}
+	}
+
+	return pins, consistent
+}
+
+// lastPinnedGroup returns the highest group index any student is fixed to,
+// or -1 without fixed assignments.
+func (s *solver) lastPinnedGroup() int {
+	last := -1
+	for _, group := range s.data.Assignments {
+		last = max(last, group)
+	}
+
+	return last
+}
+
+// validateAssignments checks the fixed assignments before any search starts:
+// every student must exist and be fixed to a group that can exist, students
+// who are required to be together must be fixed to the same group, and
+// students fixed to the same group must not exclude each other, directly or
+// through the inclusion groups they belong to. In BySubject mode they must
+// not share a subject either.
+func (s *solver) validateAssignments(studentSubject map[string]string) error {
+	if len(s.data.Assignments) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	for _, student := range s.data.Students {
+		known[student] = true
+	}
+	for _, students := range s.data.SubjectStudents {
+		for _, student := range students {
+			known[student] = true
+		}
+	}
+
+	fixed := slices.Sorted(maps.Keys(s.data.Assignments))
+	for _, student := range fixed {
+		group := s.data.Assignments[student]
+		switch {
+		case !known[student]:
+			return invalidInput("student %q is fixed to a group but is not one of the students", student)
+		case group < 0:
+			return invalidInput("student %q is fixed to group index %d, which is negative", student, group)
+		case s.opts.Mode.countsGroups() && s.opts.NumGroups > 0 && group >= s.opts.NumGroups:
+			err := infeasible("student %q is fixed to group %d, but there are only %s", student, group+1, pluralize(s.opts.NumGroups, "group"))
+			err.Conflict = []ConflictingConstraint{s.assignmentConstraint(student)}
+			return err
+		}
+	}
+
+	if s.opts.Mode == BySubject && s.opts.MinSize > 0 {
+		if _, maxGroups := s.subjectGroupCountRange(); s.lastPinnedGroup() >= maxGroups {
+			return infeasible("students are fixed to group %d, but %d students allow at most %s of at least %d", s.lastPinnedGroup()+1, s.studentCount(), pluralize(maxGroups, "group"), s.opts.MinSize)
+		}
+	}
+
+	// Students who must be together must be fixed to the same group.
+	inclusionOf := make(map[string]int)
+	for inclusionIndex, inclusionGroup := range s.data.Inclusions {
+		first := ""
+		for _, student := range inclusionGroup {
+			inclusionOf[student] = inclusionIndex
+			group, isFixed := s.data.Assignments[student]
+			if !isFixed {
+				continue
+			}
+			if first == "" {
+				first = student
+				continue
+			}
+			if firstGroup := s.data.Assignments[first]; group != firstGroup {
+				err := infeasible("students %q and %q are required to be together but are fixed to groups %d and %d", first, student, firstGroup+1, group+1)
+				err.Conflict = []ConflictingConstraint{
+					s.assignmentConstraint(first),
+					s.assignmentConstraint(student),
+					s.inclusionConstraint(inclusionIndex, first, student),
+				}
+				return err
+			}
+		}
+	}
+
+	// The units of students fixed to the same group end up together, so none
+	// of their students may exclude each other or share a subject.
+	unitOf := func(student string) []string {
+		if inclusionIndex, included := inclusionOf[student]; included {
+			return s.data.Inclusions[inclusionIndex]
+		}
+		return []string{student}
+	}
+	for i, student := range fixed {
+		for _, other := range fixed[i+1:] {
+			group := s.data.Assignments[student]
+			if s.data.Assignments[other] != group {
+				continue
+			}
+			// Students of one inclusion group are a single unit.
+			if inclusionIndex, included := inclusionOf[student]; included {
+				if otherIndex, otherIncluded := inclusionOf[other]; otherIncluded && otherIndex == inclusionIndex {
+					continue
+				}
+			}
+
+			for _, member := range unitOf(student) {
+				for _, otherMember := range unitOf(other) {
+					subject, hasSubject := studentSubject[member]
+					sameSubject := hasSubject && subject == studentSubject[otherMember]
+					if !sameSubject && !studentsConflict(member, otherMember, s.exclusionLookup) {
+						continue
+					}
+
+					reason := "are listed in an exclusion group"
+					if sameSubject {
+						reason = fmt.Sprintf("both belong to subject %q", subject)
+					}
+					var err *InfeasibleError
+					if member == student && otherMember == other {
+						err = infeasible("students %q and %q are fixed to group %d but %s", student, other, group+1, reason)
+					} else {
+						err = infeasible("students %q and %q are fixed to group %d, but that puts %q and %q together, who %s", student, other, group+1, member, otherMember, reason)
+					}
+					err.Conflict = []ConflictingConstraint{s.assignmentConstraint(student), s.assignmentConstraint(other)}
+					if member != student {
+						err.Conflict = append(err.Conflict, s.inclusionConstraint(inclusionOf[student], student, member))
+					}
+					if otherMember != other {
+						err.Conflict = append(err.Conflict, s.inclusionConstraint(inclusionOf[other], other, otherMember))
+					}
+					if !sameSubject {
+						err.Conflict = append(err.Conflict, s.exclusionConstraint(member, otherMember))
+					}
+					return err
+				}
+			}
+		}
+	}
+
+	// The units fixed to a group must fit into it.
+	sizes := make(map[int]int)
+	counted := make(map[string]bool)
+	for _, student := range fixed {
+		for _, member := range unitOf(student) {
+			if !counted[member] {
+				counted[member] = true
+				sizes[s.data.Assignments[student]]++
+			}
+		}
+	}
+	for _, group := range slices.Sorted(maps.Keys(sizes)) {
+		limit := s.groupLimit(group)
+		if limit == 0 || sizes[group] <= limit {
+			continue
+		}
+		err := infeasible("%d students are fixed to group %d together with the students required to be with them, more than the %d it takes", sizes[group], group+1, limit)
+		for _, student := range fixed {
+			if s.data.Assignments[student] == group {
+				err.Conflict = append(err.Conflict, s.assignmentConstraint(student))
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *solver) assignmentConstraint(student string) ConflictingConstraint {
+	group := s.data.Assignments[student]
+	return ConflictingConstraint{
+		Kind:     AssignmentConstraint,
+		Index:    group,
+		Students: []string{student},
+		Cells:    s.constraintCells(AssignmentConstraint, group, []string{student}),
+	}
+}
+
+func (s *solver) inclusionConstraint(index int, students ...string) ConflictingConstraint {
+	return ConflictingConstraint{
+		Kind:     InclusionConstraint,
+		Index:    index,
+		Students: students,
+		Cells:    s.constraintCells(InclusionConstraint, index, students),
+	}
+}
+
+// exclusionConstraint returns the exclusion group that keeps student and
+// other apart.
+func (s *solver) exclusionConstraint(student string, other string) ConflictingConstraint {
+	pair := []string{student, other}
+	for index, exclusionGroup := range s.data.Exclusions {
+		if slices.Contains(exclusionGroup, student) && slices.Contains(exclusionGroup, other) {
+			return ConflictingConstraint{
+				Kind:     ExclusionConstraint,
+				Index:    index,
+				Students: pair,
+				Cells:    s.constraintCells(ExclusionConstraint, index, pair),
+			}
+		}
+	}
+
+	return ConflictingConstraint{Kind: ExclusionConstraint, Students: pair}
+}
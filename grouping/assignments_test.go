@@ -0,0 +1,69 @@
+package grouping
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+func TestPinnedStudents(t *testing.T) {
+	tests := []struct {
+		name string
+		mode Mode
+		data *types.GroupingData
+		// numGroups is the number of groups asked for, and the number
+		// expected in BySubject mode.
+		numGroups int
+	}{
+		{
+			name: "count mode",
+			mode: ByCount,
+			data: &types.GroupingData{
+				Students:    []string{"A", "B", "C", "D", "E", "F"},
+				Exclusions:  [][]string{{"A", "C"}, {"B", "D"}},
+				Assignments: map[string]int{"A": 2, "B": 2, "E": 0},
+			},
+			numGroups: 3,
+		},
+		{
+			name: "inclusion follows its pinned student",
+			mode: ByCount,
+			data: &types.GroupingData{
+				Students:    []string{"A", "B", "C", "D", "E", "F"},
+				Inclusions:  [][]string{{"A", "B", "C"}},
+				Exclusions:  [][]string{{"C", "D"}},
+				Assignments: map[string]int{"A": 1, "D": 0},
+			},
+			numGroups: 2,
+		},
+		{
+			name: "subject mode opens the group a student is fixed to",
+			mode: BySubject,
+			data: &types.GroupingData{
+				Subjects:        []string{"Math", "Art"},
+				SubjectStudents: map[string][]string{"Math": {"A", "B"}, "Art": {"C", "D"}},
+				Students:        []string{"A", "B", "C", "D"},
+				Assignments:     map[string]int{"C": 2},
+			},
+			numGroups: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := Options{Mode: test.mode, Seed: 1}
+			if test.mode == ByCount {
+				opts.NumGroups = test.numGroups
+			}
+			result, err := Solve(context.Background(), test.data, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Groups) != test.numGroups {
+				t.Fatalf("%d groups, want %d: %v", len(result.Groups), test.numGroups, result.Groups)
+			}
+			checkGroups(t, test.data, result.Groups)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package grouping
+
+import "sort"
+
+// AttributeDistribution shows how the values of one student attribute are
+// spread over the groups.
+type AttributeDistribution struct {
+	Attribute string
+	Values    []string
+	// Counts[g][v] is the number of students in group g with value Values[v].
+	Counts [][]int
+}
+
+// attributeTerm penalizes attribute values that are spread unevenly over the
+// groups. For every value, the squared deviations of the per-group counts
+// from their mean are summed and compared with the smallest sum whole counts
+// allow, so the penalty is zero when every value is spread as evenly as
+// possible.
+type attributeTerm struct {
+	attributes []string
+	values     map[string][]string
+	students   map[string]map[string]string
+	weight     float64
+}
+
+func (s *solver) attributeTerm() *attributeTerm {
+	if len(s.data.Attributes) == 0 || len(s.data.StudentAttributes) == 0 {
+		return nil
+	}
+
+	return &attributeTerm{
+		attributes: s.data.Attributes,
+		values:     attributeValues(s.data.Attributes, s.data.StudentAttributes),
+		students:   s.data.StudentAttributes,
+		weight:     s.weights().AttributeBalance,
+	}
+}
+
+func (a *attributeTerm) penalty(groupOf map[string]int, numGroups int) float64 {
+	total := 0.0
+	for _, attribute := range a.attributes {
+		for _, counts := range a.counts(attribute, groupOf, numGroups) {
+			total += unevenness(counts)
+		}
+	}
+
+	return a.weight * total
+}
+
+// counts returns, for every value of attribute, the number of students with
+// that value in each group.
+func (a *attributeTerm) counts(attribute string, groupOf map[string]int, numGroups int) [][]int {
+	values := a.values[attribute]
+	valueIndex := make(map[string]int, len(values))
+	counts := make([][]int, len(values))
+	for i, value := range values {
+		valueIndex[value] = i
+		counts[i] = make([]int, numGroups)
+	}
+
+	for student, group := range groupOf {
+		value, exists := a.students[student][attribute]
+		if !exists {
+			continue
+		}
+		counts[valueIndex[value]][group]++
+	}
+
+	return counts
+}
+
+func (a *attributeTerm) distribution(groups [][]string) []AttributeDistribution {
+	groupOf := groupIndex(groups)
+	distribution := make([]AttributeDistribution, 0, len(a.attributes))
+	for _, attribute := range a.attributes {
+		byValue := a.counts(attribute, groupOf, len(groups))
+		byGroup := make([][]int, len(groups))
+		for group := range groups {
+			byGroup[group] = make([]int, len(byValue))
+			for value := range byValue {
+				byGroup[group][value] = byValue[value][group]
+			}
+		}
+
+		distribution = append(distribution, AttributeDistribution{
+			Attribute: attribute,
+			Values:    a.values[attribute],
+			Counts:    byGroup,
+		})
+	}
+
+	return distribution
+}
+
+// unevenness returns how far counts are from the most even spread of their
+// total over len(counts) groups, as a difference of squared deviations.
+func unevenness(counts []int) float64 {
+	if len(counts) == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	mean := float64(total) / float64(len(counts))
+
+	squares := 0.0
+	for _, count := range counts {
+		squares += (float64(count) - mean) * (float64(count) - mean)
+	}
+
+	// The most even spread puts one more student into total%len(counts) groups.
+	base, extra := total/len(counts), total%len(counts)
+	low, high := float64(base)-mean, float64(base+1)-mean
+	best := float64(extra)*high*high + float64(len(counts)-extra)*low*low
+
+	if squares-best < 1e-9 {
+		return 0
+	}
+
+	return squares - best
+}
+
+// attributeValues returns the sorted values of every attribute.
+func attributeValues(attributes []string, students map[string]map[string]string) map[string][]string {
+	values := make(map[string][]string, len(attributes))
+	for _, attribute := range attributes {
+		seen := make(map[string]struct{})
+		for _, studentValues := range students {
+			if value, exists := studentValues[attribute]; exists {
+				seen[value] = struct{}{}
+			}
+		}
+
+		sorted := make([]string, 0, len(seen))
+		for value := range seen {
+			sorted = append(sorted, value)
+		}
+		sort.Strings(sorted)
+		values[attribute] = sorted
+	}
+
+	return values
+}
@@ -0,0 +1,85 @@
+package grouping
+
+// rangeSearch looks for an assignment of units into between minGroups and
+// maxGroups groups that respects the size limits, trying fewer groups first.
+// With Options.Balance it defers to balancedSearch. Units with a pin of 0 or
+// more are fixed to that group.
+//
+// It returns a nil slice and no error when no assignment exists.
+func (s *solver) rangeSearch(units [][]string, conflicts [][]int, pins []int, minGroups int, maxGroups int) ([][]string, error) {
+	if s.opts.Balance {
+		return s.balancedSearch(units, conflicts, pins, minGroups, maxGroups)
+	}
+
+	for numGroups := minGroups; numGroups <= maxGroups; numGroups++ {
+		groups, err := s.searchGroups(units, numGroups, conflicts, pins, s.sizeLimits())
+		if groups != nil || err != nil {
+			return groups, err
+		}
+	}
+
+	return nil, nil
+}
+
+// balancedSearch looks for the most even assignment of units into between
+// minGroups and maxGroups groups.
+//
+// Every group is first required to hold the average number of students,
+// rounded down or up. If no such assignment exists, the allowed range is
+// widened by one student on both sides at a time until an assignment is
+// found or the range reaches the size limits. For the same range, fewer
+// groups are preferred.
+//
+// It returns a nil slice and no error when no assignment exists at all.
+func (s *solver) balancedSearch(units [][]string, conflicts [][]int, pins []int, minGroups int, maxGroups int) ([][]string, error) {
+	limits := s.sizeLimits()
+	total := 0
+	for _, unit := range units {
+		total += len(unit)
+	}
+
+	for slack := 0; ; slack++ {
+		unrestricted := true
+		for numGroups := minGroups; numGroups <= maxGroups; numGroups++ {
+			bounds := sizeBounds{
+				Min: max(total/numGroups-slack, limits.Min),
+				Max: (total+numGroups-1)/numGroups + slack,
+			}
+			if limits.Max > 0 {
+				bounds.Max = min(bounds.Max, limits.Max)
+			}
+			if bounds.Min > limits.Min || (bounds.Max < total && bounds.Max != limits.Max) {
+				unrestricted = false
+			}
+			if bounds.Min > bounds.Max {
+				continue
+			}
+
+			groups, err := s.searchGroups(units, numGroups, conflicts, pins, bounds)
+			if groups != nil || err != nil {
+				return groups, err
+			}
+		}
+
+		if unrestricted {
+			return nil, nil
+		}
+	}
+}
+
+// noteSizeSpread records a note when group sizes differ by more than one student.
+func (s *solver) noteSizeSpread(groups [][]string) {
+	if len(groups) == 0 {
+		return
+	}
+
+	smallest, largest := len(groups[0]), len(groups[0])
+	for _, group := range groups[1:] {
+		smallest = min(smallest, len(group))
+		largest = max(largest, len(group))
+	}
+
+	if largest-smallest > 1 {
+		s.note("constraints do not allow equal group sizes, sizes range from %d to %d students", smallest, largest)
+	}
+}
@@ -0,0 +1,76 @@
+package grouping
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+func TestBalancedSizes(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      Mode
+		data      *types.GroupingData
+		numGroups int
+		// want are the group sizes, smallest first.
+		want []int
+	}{
+		{
+			name: "within one student",
+			mode: ByCount,
+			data: &types.GroupingData{
+				Students:   []string{"A", "B", "C", "D", "E", "F", "G"},
+				Exclusions: [][]string{{"A", "B", "C"}},
+			},
+			numGroups: 3,
+			want:      []int{2, 2, 3},
+		},
+		{
+			name: "widened around a large inclusion group",
+			mode: ByCount,
+			data: &types.GroupingData{
+				Students:   []string{"A", "B", "C", "D", "E", "F", "G"},
+				Inclusions: [][]string{{"A", "B", "C", "D"}},
+			},
+			numGroups: 3,
+			want:      []int{1, 2, 4},
+		},
+		{
+			name: "subject mode spreads the first-fit groups",
+			mode: BySubject,
+			data: &types.GroupingData{
+				Subjects: []string{"Math", "Art", "Music"},
+				SubjectStudents: map[string][]string{
+					"Math":  {"A", "B", "C"},
+					"Art":   {"D"},
+					"Music": {"E"},
+				},
+				Students: []string{"A", "B", "C", "D", "E"},
+			},
+			numGroups: 3,
+			want:      []int{1, 2, 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := Options{Mode: test.mode, Balance: true, Seed: 1}
+			if test.mode == ByCount {
+				opts.NumGroups = test.numGroups
+			}
+			result, err := Solve(context.Background(), test.data, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGroups(t, test.data, result.Groups)
+			sizes := groupSizes(result.Groups)
+			slices.Sort(sizes)
+			if !slices.Equal(sizes, test.want) {
+				t.Errorf("group sizes %v, want %v: %v", sizes, test.want, result.Groups)
+			}
+		})
+	}
+}
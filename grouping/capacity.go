@@ -0,0 +1,114 @@
+package grouping
+
+import (
+	"slices"
+
+	"github.com/kremec/edugroup/types"
+)
+
+// withNamedGroups returns opts with NumGroups taken from the named groups of
+// data in ByCount and ByPreference mode, where a NumGroups of zero means one
+// group for every named group.
+func withNamedGroups(data *types.GroupingData, opts Options) (Options, error) {
+	if !opts.Mode.countsGroups() || len(data.NamedGroups) == 0 {
+		return opts, nil
+	}
+
+	switch opts.NumGroups {
+	case 0:
+		opts.NumGroups = len(data.NamedGroups)
+	case len(data.NamedGroups):
+	default:
+		return opts, invalidInput("%s were asked for, but the data names %s", pluralize(opts.NumGroups, "group"), pluralize(len(data.NamedGroups), "group"))
+	}
+
+	return opts, nil
+}
+
+// groupLimit returns the most students the group with the given index may
+// hold, the smaller of MaxSize and its capacity, or 0 for no limit.
+func (s *solver) groupLimit(group int) int {
+	limit := s.opts.MaxSize
+	if s.opts.Mode.countsGroups() && group < len(s.data.NamedGroups) {
+		if capacity := s.data.NamedGroups[group].Capacity; capacity > 0 && (limit == 0 || capacity < limit) {
+			limit = capacity
+		}
+	}
+
+	return limit
+}
+
+// capacities returns the capacity of each of numGroups groups, or nil when
+// the groups have none.
+func (s *solver) capacities(numGroups int) []int {
+	if !s.opts.Mode.countsGroups() || len(s.data.NamedGroups) == 0 {
+		return nil
+	}
+
+	capacities := make([]int, numGroups)
+	for group := range capacities {
+		if group < len(s.data.NamedGroups) {
+			capacities[group] = s.data.NamedGroups[group].Capacity
+		}
+	}
+
+	return capacities
+}
+
+// hasCapacities reports whether any of numGroups groups has a capacity.
+func (s *solver) hasCapacities(numGroups int) bool {
+	return slices.ContainsFunc(s.capacities(numGroups), func(capacity int) bool {
+		return capacity > 0
+	})
+}
+
+// validateNamedGroups checks the capacities of the named groups against the
+// students and the size limits before any search starts.
+func (s *solver) validateNamedGroups() error {
+	if !s.opts.Mode.countsGroups() || len(s.data.NamedGroups) == 0 {
+		return nil
+	}
+
+	room, largest, unlimited := 0, 0, false
+	for _, group := range s.data.NamedGroups {
+		switch {
+		case group.Capacity < 0:
+			return invalidInput("capacity %d of group %q must not be negative", group.Capacity, group.Name)
+		case group.Capacity == 0:
+			unlimited = true
+			continue
+		case group.Capacity < s.opts.MinSize:
+			return infeasible("group %q takes %s, fewer than the minimum group size %d", group.Name, pluralize(group.Capacity, "student"), s.opts.MinSize)
+		}
+		room += group.Capacity
+		largest = max(largest, group.Capacity)
+	}
+	if unlimited {
+		return nil
+	}
+
+	if total := s.studentCount(); room < total {
+		return infeasible("%d students do not fit into the named groups, which take %s together", total, pluralize(room, "student"))
+	}
+	for _, inclusionGroup := range s.data.Inclusions {
+		if len(inclusionGroup) > largest {
+			return infeasible("students %s are required to be together, but no group takes more than %s", quoteNames(inclusionGroup), pluralize(largest, "student"))
+		}
+	}
+
+	return nil
+}
+
+// groupNames returns the names of the groups when the data names them.
+func (s *solver) groupNames() []string {
+	if !s.opts.Mode.countsGroups() || len(s.data.NamedGroups) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(s.data.NamedGroups))
+	for i, group := range s.data.NamedGroups {
+		names[i] = group.Name
+	}
+
+	return names
+}
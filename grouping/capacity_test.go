@@ -0,0 +1,120 @@
+package grouping
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+func TestNamedGroupCapacities(t *testing.T) {
+	tests := []struct {
+		name       string
+		students   int
+		groups     []types.NamedGroup
+		maxSize    int
+		infeasible bool
+	}{
+		{
+			name:     "students fill the capacities exactly",
+			students: 6,
+			groups:   []types.NamedGroup{{Name: "Lab", Capacity: 1}, {Name: "Studio", Capacity: 2}, {Name: "Hall", Capacity: 3}},
+		},
+		{
+			name:     "unlimited group takes the rest",
+			students: 7,
+			groups:   []types.NamedGroup{{Name: "Lab", Capacity: 1}, {Name: "Hall"}},
+		},
+		{
+			name:     "maximum size below the capacity",
+			students: 6,
+			groups:   []types.NamedGroup{{Name: "Lab", Capacity: 4}, {Name: "Hall", Capacity: 4}},
+			maxSize:  3,
+		},
+		{
+			name:       "more students than places",
+			students:   7,
+			groups:     []types.NamedGroup{{Name: "Lab", Capacity: 3}, {Name: "Hall", Capacity: 3}},
+			infeasible: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := &types.GroupingData{NamedGroups: test.groups}
+			for i := range test.students {
+				data.Students = append(data.Students, string(rune('A'+i)))
+			}
+			// A and B cannot share a group, so the search has to work
+			// around the capacities.
+			data.Exclusions = [][]string{{"A", "B"}}
+
+			result, err := Solve(context.Background(), data, Options{Mode: ByCount, MaxSize: test.maxSize, Seed: 1})
+			if test.infeasible {
+				var infeasibleErr *InfeasibleError
+				if !errors.As(err, &infeasibleErr) {
+					t.Fatalf("got %v, %v; want an InfeasibleError", result, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.Groups) != len(test.groups) {
+				t.Fatalf("%d groups, want %d", len(result.Groups), len(test.groups))
+			}
+			checkGroups(t, data, result.Groups)
+			for i, group := range test.groups {
+				if result.GroupNames[i] != group.Name {
+					t.Errorf("group %d is named %q, want %q", i+1, result.GroupNames[i], group.Name)
+				}
+				if group.Capacity > 0 && len(result.Groups[i]) > group.Capacity {
+					t.Errorf("group %q holds %d students, more than its capacity %d", group.Name, len(result.Groups[i]), group.Capacity)
+				}
+				if test.maxSize > 0 && len(result.Groups[i]) > test.maxSize {
+					t.Errorf("group %q holds %d students, more than the maximum size %d", group.Name, len(result.Groups[i]), test.maxSize)
+				}
+			}
+		})
+	}
+}
+
+func TestCapacitiesLeaveSizesToOptimizer(t *testing.T) {
+	// The search splits the students two and two, but only the larger group
+	// has room for the three who would like to be together.
+	data := &types.GroupingData{
+		Students:       []string{"A", "B", "C", "D"},
+		SoftInclusions: [][]string{{"A", "B", "C"}},
+		NamedGroups:    []types.NamedGroup{{Name: "Lab", Capacity: 2}, {Name: "Hall", Capacity: 4}},
+	}
+
+	result, err := Solve(context.Background(), data, Options{Mode: ByCount, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Penalty != 0 {
+		t.Errorf("penalty %g, want 0: %v", result.Penalty, result.Groups)
+	}
+	if sizes := groupSizes(result.Groups); !slices.Equal(sizes, []int{1, 3}) {
+		t.Errorf("group sizes %v, want [1 3]: %v", sizes, result.Groups)
+	}
+}
+
+func TestCapacityBelowMinimumSize(t *testing.T) {
+	data := &types.GroupingData{
+		Students:    []string{"A", "B", "C", "D"},
+		NamedGroups: []types.NamedGroup{{Name: "Lab", Capacity: 1}, {Name: "Hall"}},
+	}
+
+	_, err := Solve(context.Background(), data, Options{Mode: ByCount, MinSize: 2})
+	var infeasibleErr *InfeasibleError
+	if !errors.As(err, &infeasibleErr) {
+		t.Fatalf("got %v, want an InfeasibleError", err)
+	}
+	if want := `group "Lab" takes 1 student, fewer than the minimum group size 2`; infeasibleErr.Msg != want {
+		t.Errorf("got %q, want %q", infeasibleErr.Msg, want)
+	}
+}
@@ -0,0 +1,287 @@
+package grouping
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ConstraintKind tells exclusion and inclusion groups apart.
+type ConstraintKind int
+
+const (
+	// ExclusionConstraint is a group of students who cannot work together.
+	ExclusionConstraint ConstraintKind = iota
+	// InclusionConstraint is a group of students who must stay together.
+	InclusionConstraint
+	// AssignmentConstraint is a student fixed to a group.
+	AssignmentConstraint
+)
+
+func (k ConstraintKind) String() string {
+	switch k {
+	case ExclusionConstraint:
+		return "exclusion"
+	case InclusionConstraint:
+		return "inclusion"
+	case AssignmentConstraint:
+		return "fixed assignment"
+	default:
+		return fmt.Sprintf("ConstraintKind(%d)", int(k))
+	}
+}
+
+// ConflictingConstraint is the part of one exclusion or inclusion group, or
+// a fixed assignment, that takes part in a conflict.
+type ConflictingConstraint struct {
+	Kind ConstraintKind
+	// Index is the position of the group in GroupingData.Exclusions or
+	// GroupingData.Inclusions, or for a fixed assignment the index of the
+	// group the student is fixed to.
+	Index int
+	// Students are the students of the group that take part in the conflict.
+	Students []string
+	// Cells are the input cells of Students, when the data has them.
+	Cells []string
+}
+
+func (c ConflictingConstraint) String() string {
+	names := make([]string, len(c.Students))
+	for i, student := range c.Students {
+		names[i] = fmt.Sprintf("%q", student)
+		if i < len(c.Cells) && c.Cells[i] != "" {
+			names[i] += " (" + c.Cells[i] + ")"
+		}
+	}
+
+	if c.Kind == AssignmentConstraint {
+		return fmt.Sprintf("%s to group %d: %s", c.Kind, c.Index+1, strings.Join(names, ", "))
+	}
+
+	return fmt.Sprintf("%s group %d: %s", c.Kind, c.Index+1, strings.Join(names, ", "))
+}
+
+// constraintItem is a constraint group, or part of one, while a conflict is
+// being narrowed down.
+type constraintItem struct {
+	kind     ConstraintKind
+	index    int
+	students []string
+}
+
+// explainNumGroups narrows the exclusion and inclusion groups and the fixed
+// assignments down to a set that still cannot be met with numGroups groups, but can be as soon as any
+// student is left out of it. Every step reruns the search on all students
+// with fewer constraints; steps that run out of time keep the constraint, so
+// the set may not be minimal then. It returns nil if the constraints turn out
+// to be satisfiable on their own.
+func (s *solver) explainNumGroups(numGroups int) []ConflictingConstraint {
+	// The explanation gets a time budget of its own, as the failed search
+	// may have used up most of the first one.
+	budget := s.opts.TimeBudget
+	if budget == 0 {
+		budget = DefaultTimeBudget
+	}
+	explainer := *s
+	explainer.deadline = time.Now().Add(budget)
+	explainer.log = nil
+
+	items := make([]constraintItem, 0, len(s.data.Exclusions)+len(s.data.Inclusions)+len(s.data.Assignments))
+	for index, exclusionGroup := range s.data.Exclusions {
+		items = append(items, constraintItem{kind: ExclusionConstraint, index: index, students: exclusionGroup})
+	}
+	for index, inclusionGroup := range s.data.Inclusions {
+		items = append(items, constraintItem{kind: InclusionConstraint, index: index, students: inclusionGroup})
+	}
+	for _, student := range slices.Sorted(maps.Keys(s.data.Assignments)) {
+		items = append(items, constraintItem{kind: AssignmentConstraint, index: s.data.Assignments[student], students: []string{student}})
+	}
+	if !explainer.infeasibleWith(items, numGroups) {
+		return nil
+	}
+
+	// Leave out whole groups first, then single students of the rest.
+	for i := 0; i < len(items); {
+		without := slices.Delete(slices.Clone(items), i, i+1)
+		if explainer.infeasibleWith(without, numGroups) {
+			items = without
+			continue
+		}
+		i++
+	}
+	for i := range items {
+		for j := 0; j < len(items[i].students) && len(items[i].students) > 2; {
+			without := slices.Clone(items)
+			without[i].students = slices.Delete(slices.Clone(items[i].students), j, j+1)
+			if explainer.infeasibleWith(without, numGroups) {
+				items = without
+				continue
+			}
+			j++
+		}
+	}
+
+	conflict := make([]ConflictingConstraint, 0, len(items))
+	for _, item := range items {
+		conflict = append(conflict, ConflictingConstraint{
+			Kind:     item.kind,
+			Index:    item.index,
+			Students: item.students,
+			Cells:    s.constraintCells(item.kind, item.index, item.students),
+		})
+	}
+
+	return conflict
+}
+
+// infeasibleWith reports whether all students cannot be split into numGroups
+// groups within the size limits when only items constrain them. A search that
+// runs out of time counts as feasible.
+func (s *solver) infeasibleWith(items []constraintItem, numGroups int) bool {
+	exclusions := make([][]string, 0, len(items))
+	assignments := make(map[string]int)
+	unitOf := make(map[string]int)
+	units := make([][]string, 0, len(s.data.Students))
+	for _, item := range items {
+		switch item.kind {
+		case ExclusionConstraint:
+			exclusions = append(exclusions, item.students)
+			continue
+		case AssignmentConstraint:
+			assignments[item.students[0]] = item.index
+			continue
+		}
+		for _, student := range item.students {
+			unitOf[student] = len(units)
+		}
+		units = append(units, item.students)
+	}
+	for _, student := range s.data.Students {
+		if _, exists := unitOf[student]; !exists {
+			unitOf[student] = len(units)
+			units = append(units, []string{student})
+		}
+	}
+
+	lookup := buildExclusionLookup(exclusions)
+	conflicts := unitConflicts(units, func(unit []string, otherUnit []string) bool {
+		for _, student := range unit {
+			for _, otherStudent := range otherUnit {
+				if studentsConflict(student, otherStudent, lookup) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+
+	// A student in an inclusion group that is also excluded from another
+	// member of it, or fixed to another group than a member of it, can never
+	// be placed.
+	for _, unit := range units {
+		if unitConflictsItself(unit, lookup) {
+			return true
+		}
+	}
+	pins, consistent := unitPins(units, assignments)
+	if !consistent {
+		return true
+	}
+
+	groups, err := s.searchGroups(units, numGroups, conflicts, pins, s.sizeLimits())
+	return err == nil && groups == nil
+}
+
+func unitConflictsItself(unit []string, lookup map[string]map[string]struct{}) bool {
+	for i := range unit {
+		for j := i + 1; j < len(unit); j++ {
+			if studentsConflict(unit[i], unit[j], lookup) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// constraintCells returns the input cells of students in the given
+// constraint group, or nil when the data has no cells.
+func (s *solver) constraintCells(kind ConstraintKind, index int, students []string) []string {
+	if kind == AssignmentConstraint {
+		if cell, exists := s.data.AssignmentCells[students[0]]; exists {
+			return []string{cell}
+		}
+		return nil
+	}
+
+	groups, cells := s.data.Exclusions, s.data.ExclusionCells
+	if kind == InclusionConstraint {
+		groups, cells = s.data.Inclusions, s.data.InclusionCells
+	}
+	if index >= len(cells) || len(cells[index]) != len(groups[index]) {
+		return nil
+	}
+
+	studentCells := make([]string, len(students))
+	for i, student := range students {
+		if position := slices.Index(groups[index], student); position >= 0 {
+			studentCells[i] = cells[index][position]
+		}
+	}
+
+	return studentCells
+}
+
+// describeConflict summarizes a conflict for the error message: when the
+// exclusions alone force more units apart than there are groups, it names
+// them, otherwise it returns "".
+func describeConflict(conflict []ConflictingConstraint, numGroups int) string {
+	units := make([][]string, 0)
+	unitOf := make(map[string]int)
+	exclusions := make([][]string, 0)
+	for _, constraint := range conflict {
+		switch constraint.Kind {
+		case ExclusionConstraint:
+			exclusions = append(exclusions, constraint.Students)
+			continue
+		case AssignmentConstraint:
+			// Fixed assignments are not about units that exclude each
+			// other.
+			return ""
+		}
+		for _, student := range constraint.Students {
+			unitOf[student] = len(units)
+		}
+		units = append(units, constraint.Students)
+	}
+	for _, exclusionGroup := range exclusions {
+		for _, student := range exclusionGroup {
+			if _, exists := unitOf[student]; !exists {
+				unitOf[student] = len(units)
+				units = append(units, []string{student})
+			}
+		}
+	}
+	if len(units) <= numGroups {
+		return ""
+	}
+
+	lookup := buildExclusionLookup(exclusions)
+	for i := range units {
+		for j := i + 1; j < len(units); j++ {
+			conflict := false
+			for _, student := range units[i] {
+				for _, otherStudent := range units[j] {
+					conflict = conflict || studentsConflict(student, otherStudent, lookup)
+				}
+			}
+			if !conflict {
+				return ""
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s must all be in different groups, but there are only %s", describeUnits(units), pluralize(numGroups, "group"))
+}
@@ -0,0 +1,80 @@
+package grouping
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+func TestInfeasibleConflict(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      *types.GroupingData
+		numGroups int
+		want      []ConflictingConstraint
+	}{
+		{
+			name: "three mutually excluded students",
+			data: &types.GroupingData{
+				Students:   []string{"A", "B", "C", "D", "E", "F"},
+				Exclusions: [][]string{{"A", "B"}, {"E", "F"}, {"B", "C"}, {"A", "C"}},
+			},
+			numGroups: 2,
+			want: []ConflictingConstraint{
+				{Kind: ExclusionConstraint, Index: 0, Students: []string{"A", "B"}},
+				{Kind: ExclusionConstraint, Index: 2, Students: []string{"B", "C"}},
+				{Kind: ExclusionConstraint, Index: 3, Students: []string{"A", "C"}},
+			},
+		},
+		{
+			name: "exclusion group narrowed to its conflicting students",
+			data: &types.GroupingData{
+				Students:   []string{"A", "B", "C", "D", "E", "F"},
+				Exclusions: [][]string{{"E", "F"}, {"A", "B", "C", "D"}},
+				Inclusions: [][]string{{"A", "E"}},
+			},
+			numGroups: 2,
+			want: []ConflictingConstraint{
+				{Kind: ExclusionConstraint, Index: 1, Students: []string{"B", "C", "D"}},
+			},
+		},
+		{
+			name: "fixed assignment against an exclusion",
+			data: &types.GroupingData{
+				Students:    []string{"A", "B", "C", "D"},
+				Exclusions:  [][]string{{"A", "B"}, {"C", "D"}},
+				Assignments: map[string]int{"A": 0, "B": 0, "C": 1},
+			},
+			numGroups: 2,
+			want: []ConflictingConstraint{
+				{Kind: AssignmentConstraint, Index: 0, Students: []string{"A"}},
+				{Kind: AssignmentConstraint, Index: 0, Students: []string{"B"}},
+				{Kind: ExclusionConstraint, Index: 0, Students: []string{"A", "B"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := Solve(context.Background(), test.data, Options{Mode: ByCount, NumGroups: test.numGroups, Seed: 1})
+			var infeasibleErr *InfeasibleError
+			if !errors.As(err, &infeasibleErr) {
+				t.Fatalf("got %v, %v; want an InfeasibleError", result, err)
+			}
+
+			got := infeasibleErr.Conflict
+			if len(got) != len(test.want) {
+				t.Fatalf("conflict %v, want %v", got, test.want)
+			}
+			for i, constraint := range got {
+				want := test.want[i]
+				if constraint.Kind != want.Kind || constraint.Index != want.Index || !slices.Equal(constraint.Students, want.Students) {
+					t.Errorf("conflict %d is %v, want %v", i, constraint, want)
+				}
+			}
+		})
+	}
+}
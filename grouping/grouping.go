@@ -0,0 +1,300 @@
+// Package grouping splits students into groups while honouring exclusion
+// (students who cannot work together) and inclusion (students who must stay
+// together) constraints. Once a valid grouping is found, it is improved
+// towards the soft preferences, the classmates students nominated and an even
+// spread of student attributes without breaking any of those constraints.
+// SolveAlternatives makes several distinct groupings to choose from.
+//
+// Solve keeps all of its state, including the random source, local to the
+// call, so several groupings can run concurrently.
+package grouping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kremec/edugroup/types"
+)
+
+// Mode selects how students are grouped.
+type Mode int
+
+const (
+	// BySubject builds groups with at most one student from every subject,
+	// using as few groups as the constraints allow.
+	BySubject Mode = iota
+	// ByCount splits the students into Options.NumGroups groups.
+	ByCount
+	// ByPreference places every student in one of the named groups of the
+	// data, such as project topics, preferring the groups they ranked
+	// highest, within the capacities of the groups.
+	ByPreference
+)
+
+func (m Mode) String() string {
+	switch m {
+	case BySubject:
+		return "subject"
+	case ByCount:
+		return "count"
+	case ByPreference:
+		return "preference"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// countsGroups reports whether the mode splits the students into a number
+// of groups given up front, rather than finding the number itself.
+func (m Mode) countsGroups() bool {
+	return m == ByCount || m == ByPreference
+}
+
+// Options configure a single call to Solve.
+type Options struct {
+	Mode Mode
+	// NumGroups is the number of groups to create in ByCount mode. When
+	// the data has named groups it may be zero, which creates one group for
+	// each of them, as it always does in ByPreference mode.
+	NumGroups int
+	// Seed initialises the random source. Zero picks a new random seed,
+	// which is reported in Result.Seed. The same data, options and seed
+	// always produce the same groups, unless the time budget runs out.
+	Seed int64
+	// Balance keeps group sizes within one student of each other, or as close
+	// to that as the constraints allow. In BySubject mode this may use more
+	// groups than the plain first-fit placement.
+	Balance bool
+	// MinSize and MaxSize limit the number of students in every group.
+	// Zero means no limit.
+	MinSize int
+	MaxSize int
+	// Weights scale the penalties for unmet preferences. Nil means
+	// DefaultWeights.
+	Weights *Weights
+	// TimeBudget limits the time of the searches on top of the limits on
+	// the work they do, which alone keep results reproducible. When it
+	// runs out first, the result may differ between runs with the same
+	// seed, and Diagnostics says so. When the backtracking search runs
+	// out, the greedy placement is used. SolveAlternatives spends it on all
+	// alternatives together. Zero means DefaultTimeBudget.
+	TimeBudget time.Duration
+	// Analysis is the result of Analyze for the same data and options,
+	// which Solve reuses instead of searching for mutually excluded
+	// students again. Nil analyzes the data first.
+	Analysis *Analysis
+	// History holds earlier groupings; pairs of students who were grouped
+	// together before are kept apart where possible. Nil disables it.
+	History *History
+	// Alternatives is the number of distinct groupings SolveAlternatives
+	// looks for. Zero and one ask for a single grouping.
+	Alternatives int
+	// MinDifference is the number of students every alternative places
+	// differently from each of the others, at least. Zero means one.
+	MinDifference int
+	// Log receives a trace of every placement decision. Nil disables it.
+	Log io.Writer
+}
+
+// Result is the outcome of a successful Solve.
+type Result struct {
+	Groups [][]string
+	// GroupNames holds the name of every group when the data names them,
+	// in the order of Groups, and is nil otherwise.
+	GroupNames []string
+	// Seed is the seed that was actually used, so the run can be repeated.
+	// All results of SolveAlternatives carry the seed of the whole run,
+	// which repeats them only together.
+	Seed int64
+	// Penalty is the weighted sum of unmet preferences and wishes, places
+	// below first choices, uneven attributes and repeated pairs; zero when
+	// nothing could be improved.
+	Penalty float64
+	// Violations lists the preferences that were not met.
+	Violations []Violation
+	// UnmetWishes lists the students placed without any of the classmates
+	// they nominated, and is nil when nobody nominated anyone.
+	UnmetWishes []UnmetWish
+	// Attributes shows how every student attribute is spread over the groups.
+	Attributes []AttributeDistribution
+	// RepeatPartners lists the students grouped again with someone from an
+	// earlier grouping in Options.History.
+	RepeatPartners []RepeatPartners
+	// Satisfaction counts the students who got each of their ranked groups
+	// in ByPreference mode, and is nil otherwise.
+	Satisfaction *Satisfaction
+	Diagnostics  Diagnostics
+}
+
+// Diagnostics describes how a result was produced.
+type Diagnostics struct {
+	// Units is the number of placement units: inclusion groups plus
+	// students that are not part of any inclusion group.
+	Units int
+	// ConstrainedUnits is the number of units with at least one exclusion.
+	ConstrainedUnits int
+	Elapsed          time.Duration
+	Notes            []string
+}
+
+// InfeasibleError reports constraints that cannot all be met.
+type InfeasibleError struct {
+	Msg string
+	// Conflict, when known, is a small set of exclusion and inclusion
+	// groups that cannot all be met. Leaving any student out of it makes
+	// the rest satisfiable.
+	Conflict []ConflictingConstraint
+}
+
+func (e *InfeasibleError) Error() string {
+	if len(e.Conflict) == 0 {
+		return e.Msg
+	}
+
+	lines := make([]string, 0, len(e.Conflict)+1)
+	lines = append(lines, e.Msg+"\nThese constraints conflict:")
+	for _, constraint := range e.Conflict {
+		lines = append(lines, "- "+constraint.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func infeasible(format string, args ...any) *InfeasibleError {
+	return &InfeasibleError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// ErrInvalidInput is wrapped by every error about data or options that are
+// wrong in themselves, such as negative size limits or rankings of groups
+// that do not exist, as opposed to valid input that cannot be grouped.
+var ErrInvalidInput = errors.New("grouping: invalid input")
+
+type inputError struct {
+	msg string
+}
+
+func (e *inputError) Error() string {
+	return "grouping: " + e.msg
+}
+
+func (e *inputError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+func invalidInput(format string, args ...any) error {
+	return &inputError{msg: fmt.Sprintf(format, args...)}
+}
+
+// NewSeed returns a fresh random seed. Seeds are kept below one billion so
+// they are easy to note down and type back in.
+func NewSeed() int64 {
+	return time.Now().UnixNano()%1_000_000_000 + 1
+}
+
+// Solve groups the students in data according to opts.
+func Solve(ctx context.Context, data *types.GroupingData, opts Options) (*Result, error) {
+	return solveApartFrom(ctx, data, opts, nil, 0, time.Time{})
+}
+
+// solveApartFrom runs Solve while keeping apart, at weight for every shared
+// grouping, the pairs of students grouped together in earlier. A deadline
+// before the end of opts.TimeBudget cuts the searches short there.
+func solveApartFrom(ctx context.Context, data *types.GroupingData, opts Options, earlier *History, weight float64, deadline time.Time) (*Result, error) {
+	if data == nil {
+		return nil, fmt.Errorf("grouping: no data")
+	}
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = NewSeed()
+	}
+
+	opts, err := withNamedGroups(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	s := newSolver(ctx, data, opts, seed)
+	if !deadline.IsZero() && deadline.Before(s.deadline) {
+		s.deadline = deadline
+	}
+	if earlier != nil {
+		s.earlier = s.pairTerm(earlier, weight)
+	}
+
+	if opts.Mode.countsGroups() && opts.NumGroups <= 0 {
+		return nil, invalidInput("number of groups must be positive, got %d", opts.NumGroups)
+	}
+	if err := s.validateSizeLimits(); err != nil {
+		return nil, err
+	}
+	if err := s.validateNamedGroups(); err != nil {
+		return nil, err
+	}
+	if err := s.validateRankings(); err != nil {
+		return nil, err
+	}
+
+	// More mutually excluded units than groups fail without any search.
+	if opts.Mode.countsGroups() {
+		analysis := opts.Analysis
+		if analysis == nil {
+			analysis = s.analyze()
+		}
+		if len(analysis.Clique) > opts.NumGroups {
+			return nil, s.numGroupsInfeasible(opts.NumGroups)
+		}
+	}
+
+	var groups [][]string
+	switch opts.Mode {
+	case BySubject:
+		groups, err = s.createSubjectGroups()
+	case ByCount, ByPreference:
+		groups, err = s.createNumGroups(opts.NumGroups)
+	default:
+		return nil, fmt.Errorf("grouping: unknown mode %v", opts.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Minimize unmet preferences without breaking any hard constraint.
+	groups = s.optimize(groups)
+
+	result := &Result{
+		Groups:     groups,
+		GroupNames: s.groupNames(),
+		Seed:       seed,
+	}
+	groupOf := groupIndex(groups)
+	result.Penalty = totalPenalty(s.objectiveTerms(), groupOf, len(groups))
+	if preferences := s.preferenceTerm(); preferences != nil {
+		result.Violations = preferences.violations(groupOf)
+	}
+	if nominations := s.nominationTerm(); nominations != nil {
+		result.UnmetWishes = nominations.unmet(groupOf)
+	}
+	if attributes := s.attributeTerm(); attributes != nil {
+		result.Attributes = attributes.distribution(groups)
+	}
+	if opts.History != nil {
+		result.RepeatPartners = repeatPartners(groups, opts.History)
+	}
+	if rankings := s.rankingTerm(); rankings != nil {
+		result.Satisfaction = rankings.satisfaction(groupOf)
+	}
+
+	if s.timedOut {
+		s.note("the time budget ran out before the search limits, so the same seed may give other groups on another run; a larger time budget avoids this")
+	}
+	s.diagnostics.Elapsed = time.Since(start)
+	result.Diagnostics = s.diagnostics
+
+	return result, nil
+}
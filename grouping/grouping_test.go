@@ -0,0 +1,91 @@
+package grouping
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+// checkGroups fails t unless groups place every student of data exactly once
+// and meet its exclusions, inclusions and fixed assignments.
+func checkGroups(t *testing.T, data *types.GroupingData, groups [][]string) {
+	t.Helper()
+
+	groupOf := make(map[string]int)
+	for groupIndex, group := range groups {
+		for _, student := range group {
+			if other, placed := groupOf[student]; placed {
+				t.Fatalf("%q is in groups %d and %d: %v", student, other+1, groupIndex+1, groups)
+			}
+			groupOf[student] = groupIndex
+		}
+	}
+	for _, student := range data.Students {
+		if _, placed := groupOf[student]; !placed {
+			t.Fatalf("%q is in no group: %v", student, groups)
+		}
+	}
+	if len(groupOf) != len(data.Students) {
+		t.Fatalf("%d students placed, want %d: %v", len(groupOf), len(data.Students), groups)
+	}
+
+	for _, exclusionGroup := range data.Exclusions {
+		for i, student := range exclusionGroup {
+			for _, otherStudent := range exclusionGroup[i+1:] {
+				if groupOf[student] == groupOf[otherStudent] {
+					t.Errorf("excluded %q and %q are both in group %d: %v", student, otherStudent, groupOf[student]+1, groups)
+				}
+			}
+		}
+	}
+	for _, inclusionGroup := range data.Inclusions {
+		for _, student := range inclusionGroup[1:] {
+			if groupOf[student] != groupOf[inclusionGroup[0]] {
+				t.Errorf("included %q and %q are in different groups: %v", inclusionGroup[0], student, groups)
+			}
+		}
+	}
+	for student, group := range data.Assignments {
+		if groupOf[student] != group {
+			t.Errorf("%q is in group %d, want group %d: %v", student, groupOf[student]+1, group+1, groups)
+		}
+	}
+}
+
+func groupSizes(groups [][]string) []int {
+	sizes := make([]int, len(groups))
+	for i, group := range groups {
+		sizes[i] = len(group)
+	}
+
+	return sizes
+}
+
+func TestSameSeedSameGroups(t *testing.T) {
+	data := &types.GroupingData{
+		Students:       []string{"A", "B", "C", "D", "E", "F", "G", "H", "I"},
+		Exclusions:     [][]string{{"A", "B"}, {"C", "D", "E"}},
+		SoftInclusions: [][]string{{"A", "F"}, {"G", "H"}},
+		SoftExclusions: [][]string{{"B", "I"}},
+	}
+	opts := Options{Mode: ByCount, NumGroups: 3, Seed: 42}
+
+	first, err := Solve(context.Background(), data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		again, err := Solve(context.Background(), data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.EqualFunc(first.Groups, again.Groups, slices.Equal) {
+			t.Fatalf("seed %d gave %v, then %v", opts.Seed, first.Groups, again.Groups)
+		}
+		if len(again.Diagnostics.Notes) > 0 {
+			t.Errorf("unexpected notes %v", again.Diagnostics.Notes)
+		}
+	}
+}
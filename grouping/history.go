@@ -0,0 +1,121 @@
+package grouping
+
+// History counts how often pairs of students shared a group in earlier
+// groupings. Pass it in Options.History to avoid repeating those pairs.
+type History struct {
+	pairs  map[[2]string]int
+	rounds int
+}
+
+// NewHistory returns an empty history.
+func NewHistory() *History {
+	return &History{pairs: make(map[[2]string]int)}
+}
+
+// AddRound records one earlier grouping.
+func (h *History) AddRound(groups [][]string) {
+	for _, group := range groups {
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				h.pairs[historyPair(group[i], group[j])]++
+			}
+		}
+	}
+	h.rounds++
+}
+
+// Rounds returns the number of groupings recorded.
+func (h *History) Rounds() int {
+	return h.rounds
+}
+
+// Count returns how often students a and b shared a group.
+func (h *History) Count(a string, b string) int {
+	return h.pairs[historyPair(a, b)]
+}
+
+func historyPair(a string, b string) [2]string {
+	if b < a {
+		a, b = b, a
+	}
+
+	return [2]string{a, b}
+}
+
+// RepeatPartners lists the students a student already shared a group with
+// in an earlier grouping and is grouped with again.
+type RepeatPartners struct {
+	Student  string
+	Partners []string
+}
+
+// historyTerm penalizes every pair of students who share a group again, once
+// for every earlier grouping in which they were together.
+type historyTerm struct {
+	pairs  [][2]string
+	counts []int
+	weight float64
+}
+
+func (s *solver) historyTerm() *historyTerm {
+	if s.opts.History == nil {
+		return nil
+	}
+
+	return s.pairTerm(s.opts.History, s.weights().RepeatPartners)
+}
+
+// pairTerm returns a historyTerm over the pairs of students who shared a
+// group in history, or nil when no pair did.
+func (s *solver) pairTerm(history *History, weight float64) *historyTerm {
+	term := &historyTerm{weight: weight}
+	students := s.data.Students
+	if s.opts.Mode == BySubject {
+		students = flattenSubjectStudentsBySubject(s.data)
+	}
+	for i := 0; i < len(students); i++ {
+		for j := i + 1; j < len(students); j++ {
+			if count := history.Count(students[i], students[j]); count > 0 {
+				term.pairs = append(term.pairs, [2]string{students[i], students[j]})
+				term.counts = append(term.counts, count)
+			}
+		}
+	}
+	if len(term.pairs) == 0 {
+		return nil
+	}
+
+	return term
+}
+
+func (h *historyTerm) penalty(groupOf map[string]int, _ int) float64 {
+	total := 0
+	for i, pair := range h.pairs {
+		if sameGroup(groupOf, pair) {
+			total += h.counts[i]
+		}
+	}
+
+	return h.weight * float64(total)
+}
+
+// repeatPartners returns, for every student with a repeat partner, the
+// partners in the same group they were grouped with before.
+func repeatPartners(groups [][]string, history *History) []RepeatPartners {
+	repeats := make([]RepeatPartners, 0)
+	for _, group := range groups {
+		for _, student := range group {
+			partners := make([]string, 0)
+			for _, other := range group {
+				if other != student && history.Count(student, other) > 0 {
+					partners = append(partners, other)
+				}
+			}
+			if len(partners) > 0 {
+				repeats = append(repeats, RepeatPartners{Student: student, Partners: partners})
+			}
+		}
+	}
+
+	return repeats
+}
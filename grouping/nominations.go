@@ -0,0 +1,65 @@
+package grouping
+
+import "strings"
+
+// UnmetWish is a student who shares a group with none of the classmates they
+// nominated.
+type UnmetWish struct {
+	Student string
+	Wishes  []string
+}
+
+func (u UnmetWish) String() string {
+	return u.Student + " is with none of " + strings.Join(u.Wishes, ", ")
+}
+
+// nominationTerm penalizes every student who shares a group with none of the
+// classmates they nominated.
+type nominationTerm struct {
+	nominations [][]string
+	weight      float64
+}
+
+func (s *solver) nominationTerm() *nominationTerm {
+	nominations := make([][]string, 0, len(s.data.Nominations))
+	for _, nomination := range s.data.Nominations {
+		if len(nomination) > 1 {
+			nominations = append(nominations, nomination)
+		}
+	}
+	if len(nominations) == 0 {
+		return nil
+	}
+
+	return &nominationTerm{nominations: nominations, weight: s.weights().Nominations}
+}
+
+func (n *nominationTerm) penalty(groupOf map[string]int, _ int) float64 {
+	return n.weight * float64(len(n.unmet(groupOf)))
+}
+
+// unmet lists the nominating students, in input order, who were placed in a
+// group without any of their wishes.
+func (n *nominationTerm) unmet(groupOf map[string]int) []UnmetWish {
+	unmet := make([]UnmetWish, 0)
+	for _, nomination := range n.nominations {
+		student, wishes := nomination[0], nomination[1:]
+		group, placed := groupOf[student]
+		if !placed {
+			continue
+		}
+
+		met := false
+		for _, wish := range wishes {
+			if wishGroup, wishPlaced := groupOf[wish]; wishPlaced && wishGroup == group {
+				met = true
+				break
+			}
+		}
+		if !met {
+			unmet = append(unmet, UnmetWish{Student: student, Wishes: wishes})
+		}
+	}
+
+	return unmet
+}
@@ -0,0 +1,321 @@
+package grouping
+
+import (
+	"time"
+)
+
+// optimizeEvaluationLimit bounds the groupings a localSearch evaluates, so
+// that the same seed stops improving at the same point on every computer.
+const optimizeEvaluationLimit = 200_000
+
+// Weights scale the penalty terms minimized after a valid grouping is found.
+type Weights struct {
+	// PreferApart is the penalty for every pair of students from the same
+	// soft exclusion group that ends up in the same group.
+	PreferApart float64
+	// PreferTogether is the penalty for every pair of students from the same
+	// soft inclusion group that ends up in different groups.
+	PreferTogether float64
+	// Rankings is the penalty for every place a student in ByPreference
+	// mode is below their first choice, where a group they did not rank
+	// counts as one place below their last choice.
+	Rankings float64
+	// Nominations is the penalty for every student who shares a group with
+	// none of the classmates they nominated.
+	Nominations float64
+	// AttributeBalance scales the penalty for attribute values, such as
+	// gender or skill level, that are spread unevenly over the groups.
+	AttributeBalance float64
+	// RepeatPartners is the penalty for every pair of students who share a
+	// group again, counted once for every earlier grouping in Options.History
+	// in which they were together.
+	RepeatPartners float64
+}
+
+// DefaultWeights returns the weights used when Options.Weights is nil.
+func DefaultWeights() Weights {
+	return Weights{
+		PreferApart:      1,
+		PreferTogether:   1,
+		Rankings:         1,
+		Nominations:      1,
+		AttributeBalance: 1,
+		RepeatPartners:   1,
+	}
+}
+
+// objectiveTerm is one part of the penalty the optimizer minimizes. groupOf
+// maps every student to the index of their group.
+type objectiveTerm interface {
+	penalty(groupOf map[string]int, numGroups int) float64
+}
+
+func (s *solver) weights() Weights {
+	if s.opts.Weights != nil {
+		return *s.opts.Weights
+	}
+
+	return DefaultWeights()
+}
+
+// objectiveTerms returns the penalty terms that apply to the data. An empty
+// slice means any valid grouping is as good as any other.
+func (s *solver) objectiveTerms() []objectiveTerm {
+	terms := make([]objectiveTerm, 0)
+	if preferences := s.preferenceTerm(); preferences != nil {
+		terms = append(terms, preferences)
+	}
+	if rankings := s.rankingTerm(); rankings != nil {
+		terms = append(terms, rankings)
+	}
+	if nominations := s.nominationTerm(); nominations != nil {
+		terms = append(terms, nominations)
+	}
+	if attributes := s.attributeTerm(); attributes != nil {
+		terms = append(terms, attributes)
+	}
+	if history := s.historyTerm(); history != nil {
+		terms = append(terms, history)
+	}
+
+	return terms
+}
+
+func totalPenalty(terms []objectiveTerm, groupOf map[string]int, numGroups int) float64 {
+	total := 0.0
+	for _, term := range terms {
+		total += term.penalty(groupOf, numGroups)
+	}
+
+	return total
+}
+
+// groupIndex maps every student to the index of their group.
+func groupIndex(groups [][]string) map[string]int {
+	groupOf := make(map[string]int)
+	for groupIndex, group := range groups {
+		for _, student := range group {
+			groupOf[student] = groupIndex
+		}
+	}
+
+	return groupOf
+}
+
+// localSearch improves a valid assignment of units to groups by moving single
+// units to another group and swapping units between groups, keeping every
+// change that lowers the penalty, until no such change is left or the
+// evaluation limit or the time budget runs out. Moves never join conflicting units, never take a group
+// outside the size bounds and never move a unit that is fixed to its group.
+type localSearch struct {
+	solver     *solver
+	terms      []objectiveTerm
+	units      [][]string
+	conflict   [][]bool
+	unitGroup  []int
+	pinned     []bool
+	groupSizes []int
+	bounds     sizeBounds
+	groupOf    map[string]int
+	evaluated  int
+}
+
+// optimize runs a localSearch over groups when there is anything to
+// minimize, and returns the improved groups.
+func (s *solver) optimize(groups [][]string) [][]string {
+	terms := s.objectiveTerms()
+	if s.earlier != nil {
+		terms = append(terms, s.earlier)
+	}
+	if len(terms) == 0 || len(s.units) == 0 {
+		return groups
+	}
+
+	search := &localSearch{
+		solver:     s,
+		terms:      terms,
+		units:      s.units,
+		conflict:   make([][]bool, len(s.units)),
+		unitGroup:  make([]int, len(s.units)),
+		pinned:     make([]bool, len(s.units)),
+		groupSizes: make([]int, len(groups)),
+		groupOf:    groupIndex(groups),
+	}
+	for unit := range s.units {
+		search.conflict[unit] = make([]bool, len(s.units))
+		search.unitGroup[unit] = search.groupOf[s.units[unit][0]]
+		search.pinned[unit] = unit < len(s.unitPins) && s.unitPins[unit] >= 0
+	}
+	for unit, others := range s.unitConflicts {
+		for _, other := range others {
+			search.conflict[unit][other] = true
+		}
+	}
+
+	smallest, largest := len(groups[0]), len(groups[0])
+	for groupIndex, group := range groups {
+		search.groupSizes[groupIndex] = len(group)
+		smallest = min(smallest, len(group))
+		largest = max(largest, len(group))
+	}
+
+	// Keep balanced groups balanced, and the even sizes the search picks
+	// for ByCount groups without capacities. Otherwise only the size limits
+	// and the capacities bound the moves, as groups of unequal capacities
+	// may well differ in size. Groups are never emptied, except for the
+	// groups of ByPreference mode that nobody needs to be in.
+	search.bounds = s.sizeLimits()
+	if s.opts.Balance || (s.opts.Mode == ByCount && !s.hasCapacities(len(groups))) {
+		search.bounds = sizeBounds{Min: max(smallest, search.bounds.Min), Max: largest}
+	}
+	if s.opts.Mode != ByPreference {
+		search.bounds.Min = max(search.bounds.Min, min(smallest, 1))
+	}
+
+	before := totalPenalty(terms, search.groupOf, len(groups))
+	after := search.run(before)
+	s.debugf("Optimization lowered the penalty from %g to %g after %d evaluations\n", before, after, search.evaluated)
+
+	improved := make([][]string, len(groups))
+	for unit, group := range search.unitGroup {
+		improved[group] = append(improved[group], s.units[unit]...)
+	}
+
+	return improved
+}
+
+func (l *localSearch) run(current float64) float64 {
+	numGroups := len(l.groupSizes)
+	order := make([]int, len(l.units))
+	for i := range order {
+		order[i] = i
+	}
+
+	for improved := true; improved && current > 0; {
+		improved = false
+		l.solver.rng.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+
+		for _, unit := range order {
+			for group := 0; group < numGroups; group++ {
+				if l.outOfTime() {
+					return current
+				}
+				if !l.canMove(unit, group) {
+					continue
+				}
+
+				from := l.unitGroup[unit]
+				l.move(unit, group)
+				if score := l.score(); score < current {
+					current = score
+					improved = true
+					continue
+				}
+				l.move(unit, from)
+			}
+
+			for _, other := range order {
+				if l.outOfTime() {
+					return current
+				}
+				if !l.canSwap(unit, other) {
+					continue
+				}
+
+				l.swap(unit, other)
+				if score := l.score(); score < current {
+					current = score
+					improved = true
+					continue
+				}
+				l.swap(unit, other)
+			}
+		}
+	}
+
+	return current
+}
+
+func (l *localSearch) score() float64 {
+	l.evaluated++
+	return totalPenalty(l.terms, l.groupOf, len(l.groupSizes))
+}
+
+func (l *localSearch) outOfTime() bool {
+	if l.evaluated >= optimizeEvaluationLimit {
+		return true
+	}
+	if l.evaluated%256 != 0 {
+		return false
+	}
+	if time.Now().After(l.solver.deadline) {
+		l.solver.timedOut = true
+		return true
+	}
+
+	return l.solver.ctx.Err() != nil
+}
+
+// fitsWith reports whether unit can join group without a conflict, ignoring
+// the unit skip that is about to leave the group.
+func (l *localSearch) fitsWith(unit int, group int, skip int) bool {
+	for other, otherGroup := range l.unitGroup {
+		if otherGroup == group && other != skip && other != unit && l.conflict[unit][other] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sizeAllowed reports whether group may hold size students.
+func (l *localSearch) sizeAllowed(group int, size int) bool {
+	if limit := l.solver.groupLimit(group); limit > 0 && size > limit {
+		return false
+	}
+
+	return size >= l.bounds.Min && (l.bounds.Max == 0 || size <= l.bounds.Max)
+}
+
+func (l *localSearch) canMove(unit int, group int) bool {
+	from := l.unitGroup[unit]
+	if from == group || l.pinned[unit] {
+		return false
+	}
+
+	size := len(l.units[unit])
+	return l.sizeAllowed(from, l.groupSizes[from]-size) &&
+		l.sizeAllowed(group, l.groupSizes[group]+size) &&
+		l.fitsWith(unit, group, -1)
+}
+
+func (l *localSearch) canSwap(unit int, other int) bool {
+	group, otherGroup := l.unitGroup[unit], l.unitGroup[other]
+	if unit >= other || group == otherGroup || l.pinned[unit] || l.pinned[other] {
+		return false
+	}
+
+	difference := len(l.units[other]) - len(l.units[unit])
+	return l.sizeAllowed(group, l.groupSizes[group]+difference) &&
+		l.sizeAllowed(otherGroup, l.groupSizes[otherGroup]-difference) &&
+		l.fitsWith(unit, otherGroup, other) &&
+		l.fitsWith(other, group, unit)
+}
+
+func (l *localSearch) move(unit int, group int) {
+	size := len(l.units[unit])
+	l.groupSizes[l.unitGroup[unit]] -= size
+	l.groupSizes[group] += size
+	l.unitGroup[unit] = group
+	for _, student := range l.units[unit] {
+		l.groupOf[student] = group
+	}
+}
+
+func (l *localSearch) swap(unit int, other int) {
+	group, otherGroup := l.unitGroup[unit], l.unitGroup[other]
+	l.move(unit, otherGroup)
+	l.move(other, group)
+}
@@ -0,0 +1,113 @@
+package grouping
+
+import "fmt"
+
+// PreferenceKind tells soft exclusions and soft inclusions apart.
+type PreferenceKind int
+
+const (
+	// PreferApart marks students who should better not work together.
+	PreferApart PreferenceKind = iota
+	// PreferTogether marks students who would preferably work together.
+	PreferTogether
+)
+
+func (k PreferenceKind) String() string {
+	switch k {
+	case PreferApart:
+		return "prefer apart"
+	case PreferTogether:
+		return "prefer together"
+	default:
+		return fmt.Sprintf("PreferenceKind(%d)", int(k))
+	}
+}
+
+// Violation is a soft preference between two students that was not met.
+type Violation struct {
+	Kind     PreferenceKind
+	Students [2]string
+}
+
+func (v Violation) String() string {
+	if v.Kind == PreferApart {
+		return fmt.Sprintf("%s and %s are in the same group", v.Students[0], v.Students[1])
+	}
+
+	return fmt.Sprintf("%s and %s are in different groups", v.Students[0], v.Students[1])
+}
+
+// preferenceTerm penalizes every pair of students from a soft exclusion
+// group that shares a group, and every pair from a soft inclusion group that
+// does not.
+type preferenceTerm struct {
+	apart    [][2]string
+	together [][2]string
+	weights  Weights
+}
+
+func (s *solver) preferenceTerm() *preferenceTerm {
+	term := &preferenceTerm{
+		apart:    studentPairs(s.data.SoftExclusions),
+		together: studentPairs(s.data.SoftInclusions),
+		weights:  s.weights(),
+	}
+	if len(term.apart) == 0 && len(term.together) == 0 {
+		return nil
+	}
+
+	return term
+}
+
+func (p *preferenceTerm) penalty(groupOf map[string]int, _ int) float64 {
+	total := 0.0
+	for _, violation := range p.violations(groupOf) {
+		if violation.Kind == PreferApart {
+			total += p.weights.PreferApart
+		} else {
+			total += p.weights.PreferTogether
+		}
+	}
+
+	return total
+}
+
+func (p *preferenceTerm) violations(groupOf map[string]int) []Violation {
+	violations := make([]Violation, 0)
+	for _, pair := range p.apart {
+		if sameGroup(groupOf, pair) {
+			violations = append(violations, Violation{Kind: PreferApart, Students: pair})
+		}
+	}
+	for _, pair := range p.together {
+		if bothPlaced(groupOf, pair) && !sameGroup(groupOf, pair) {
+			violations = append(violations, Violation{Kind: PreferTogether, Students: pair})
+		}
+	}
+
+	return violations
+}
+
+func sameGroup(groupOf map[string]int, pair [2]string) bool {
+	return bothPlaced(groupOf, pair) && groupOf[pair[0]] == groupOf[pair[1]]
+}
+
+func bothPlaced(groupOf map[string]int, pair [2]string) bool {
+	_, placed := groupOf[pair[0]]
+	_, otherPlaced := groupOf[pair[1]]
+	return placed && otherPlaced
+}
+
+// studentPairs lists every pair of students that share one of the groups.
+func studentPairs(groups [][]string) [][2]string {
+	pairs := make([][2]string, 0)
+	for _, group := range groups {
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				pairs = append(pairs, [2]string{group[i], group[j]})
+			}
+		}
+	}
+
+	return pairs
+}
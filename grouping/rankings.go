@@ -0,0 +1,215 @@
+package grouping
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+)
+
+// Satisfaction tells how many students of a ByPreference grouping got which
+// of the groups they ranked.
+type Satisfaction struct {
+	// Choices[i] is the number of students placed in the group they ranked
+	// i+1st.
+	Choices []int
+	// Unranked is the number of students placed in a group they did not rank,
+	// including students without any ranking.
+	Unranked int
+	// StudentChoices maps every student to the place of their group in their
+	// ranking, 1 for their first choice, or 0 for a group they did not rank.
+	StudentChoices map[string]int
+}
+
+// rankingTerm penalizes every student in ByPreference mode by how far their
+// group is down their ranking.
+type rankingTerm struct {
+	students []string
+	rankings map[string][]int
+	weight   float64
+}
+
+func (s *solver) rankingTerm() *rankingTerm {
+	if s.opts.Mode != ByPreference || len(s.data.Rankings) == 0 {
+		return nil
+	}
+
+	return &rankingTerm{
+		students: s.data.Students,
+		rankings: s.data.Rankings,
+		weight:   s.weights().Rankings,
+	}
+}
+
+// cost returns how many places group is below the first choice of student,
+// one more than their last choice for a group they did not rank, and 0 for
+// students without a ranking.
+func (r *rankingTerm) cost(student string, group int) int {
+	ranking := r.rankings[student]
+	if place := slices.Index(ranking, group); place >= 0 {
+		return place
+	}
+
+	return len(ranking)
+}
+
+func (r *rankingTerm) penalty(groupOf map[string]int, _ int) float64 {
+	total := 0
+	for student := range r.rankings {
+		if group, placed := groupOf[student]; placed {
+			total += r.cost(student, group)
+		}
+	}
+
+	return r.weight * float64(total)
+}
+
+func (r *rankingTerm) satisfaction(groupOf map[string]int) *Satisfaction {
+	satisfaction := &Satisfaction{StudentChoices: make(map[string]int)}
+	for _, student := range r.students {
+		group, placed := groupOf[student]
+		if !placed {
+			continue
+		}
+
+		place := slices.Index(r.rankings[student], group)
+		if place < 0 {
+			satisfaction.Unranked++
+			satisfaction.StudentChoices[student] = 0
+			continue
+		}
+		for len(satisfaction.Choices) <= place {
+			satisfaction.Choices = append(satisfaction.Choices, 0)
+		}
+		satisfaction.Choices[place]++
+		satisfaction.StudentChoices[student] = place + 1
+	}
+
+	return satisfaction
+}
+
+// validateRankings checks that ByPreference mode has named groups to rank
+// and that every ranking names known students and groups, each at most once.
+func (s *solver) validateRankings() error {
+	if s.opts.Mode != ByPreference {
+		return nil
+	}
+	if len(s.data.NamedGroups) == 0 {
+		return invalidInput("preference mode needs named groups for the students to rank")
+	}
+
+	known := make(map[string]bool)
+	for _, student := range s.data.Students {
+		known[student] = true
+	}
+	for _, student := range slices.Sorted(maps.Keys(s.data.Rankings)) {
+		if !known[student] {
+			return invalidInput("student %q ranks groups but is not one of the students", student)
+		}
+
+		seen := make(map[int]bool)
+		for _, group := range s.data.Rankings[student] {
+			switch {
+			case group < 0 || group >= len(s.data.NamedGroups):
+				return invalidInput("student %q ranks group %d, but there are only %s", student, group+1, pluralize(len(s.data.NamedGroups), "group"))
+			case seen[group]:
+				return invalidInput("student %q ranks group %q twice", student, s.data.NamedGroups[group].Name)
+			}
+			seen[group] = true
+		}
+	}
+
+	return nil
+}
+
+// rankedGroups places every unit, after the units fixed to a group, into the
+// group its students ranked best that it fits into, and returns nil when some
+// unit fits nowhere or a group stays below the minimum size, so that the
+// search has to find the groups instead.
+func (s *solver) rankedGroups(numGroups int) [][]string {
+	rankings := s.rankingTerm()
+	if rankings == nil {
+		return nil
+	}
+
+	groups := make([][]string, numGroups)
+	unitGroup := make([]int, len(s.units))
+	fits := func(unit int, group int) bool {
+		if limit := s.groupLimit(group); limit > 0 && len(groups[group])+len(s.units[unit]) > limit {
+			return false
+		}
+		for _, other := range s.unitConflicts[unit] {
+			if unitGroup[other] == group {
+				return false
+			}
+		}
+		return true
+	}
+
+	for unit := range s.units {
+		unitGroup[unit] = -1
+		if group := s.unitPins[unit]; group >= 0 {
+			unitGroup[unit] = group
+			groups[group] = append(groups[group], s.units[unit]...)
+		}
+	}
+
+	order := make([]int, numGroups)
+	for unit, students := range s.units {
+		if unitGroup[unit] >= 0 {
+			continue
+		}
+
+		// The students of an inclusion group share their rankings.
+		cost := make([]int, numGroups)
+		for group := range cost {
+			order[group] = group
+			for _, student := range students {
+				cost[group] += rankings.cost(student, group)
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return cost[order[i]] < cost[order[j]]
+		})
+
+		for _, group := range order {
+			if fits(unit, group) {
+				s.debugf("Placing %v in ranked group %d\n", students, group+1)
+				unitGroup[unit] = group
+				groups[group] = append(groups[group], students...)
+				break
+			}
+		}
+		if unitGroup[unit] < 0 {
+			s.debugf("No ranked group is open to %v, searching instead\n", students)
+			return nil
+		}
+	}
+
+	if !s.withinSizeLimits(groups) {
+		return nil
+	}
+
+	return groups
+}
+
+// ChoiceLabel describes a place in a ranking as "1st choice", "2nd choice"
+// and so on, and a place of 0 as "not ranked".
+func ChoiceLabel(place int) string {
+	if place <= 0 {
+		return "not ranked"
+	}
+
+	suffix := "th"
+	switch {
+	case place%100 >= 11 && place%100 <= 13:
+	case place%10 == 1:
+		suffix = "st"
+	case place%10 == 2:
+		suffix = "nd"
+	case place%10 == 3:
+		suffix = "rd"
+	}
+
+	return fmt.Sprintf("%d%s choice", place, suffix)
+}
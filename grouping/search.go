@@ -0,0 +1,281 @@
+package grouping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// DefaultTimeBudget is used when Options.TimeBudget is zero.
+const DefaultTimeBudget = 5 * time.Second
+
+// searchNodeLimit bounds the nodes a single backtracking search visits. The
+// work is counted rather than timed, so the same seed gives up at the same
+// point on every computer.
+const searchNodeLimit = 1_000_000
+
+var errSearchBudget = errors.New("search budget exhausted")
+
+// errTimeBudget is errSearchBudget when the time budget ran out before the
+// node limit, which depends on the speed of the computer.
+var errTimeBudget = fmt.Errorf("%w: time budget exhausted", errSearchBudget)
+
+// unitSearch is a backtracking search that places every unit into one of
+// numGroups groups so that no group holds two conflicting units and every
+// group size stays within [minSize, maxSize].
+//
+// Units are picked most-constrained first (fewest groups still open to them)
+// and tried in the smallest groups first, so the first assignment found
+// stays close to balanced. Units fixed to a group are placed there before the
+// search starts. After every placement each remaining
+// unit must still have at least one open group, otherwise the search
+// backtracks immediately, and so it does when the unplaced students can no
+// longer fill every group up to minSize. Empty groups of the same capacity
+// are interchangeable, so only one of them is ever tried for a unit.
+type unitSearch struct {
+	ctx       context.Context
+	deadline  time.Time
+	units     [][]string
+	conflicts [][]int
+	numGroups int
+	minSize   int
+	maxSize   int
+	// capacities limits the size of every group on top of maxSize; nil or
+	// zero means no limit.
+	capacities []int
+	remaining  int
+	assignment []int
+	groupSizes []int
+	// blocked[u][g] counts the units in group g that conflict with unit u.
+	blocked [][]int
+	nodes   int
+}
+
+// sizeBounds limits the number of students in every group. Zero Max means
+// no upper limit.
+type sizeBounds struct {
+	Min, Max int
+}
+
+// searchGroups runs a unitSearch over units, with every unit that has a pin
+// of 0 or more fixed to that group. It returns a nil slice and no error when
+// no assignment exists.
+func (s *solver) searchGroups(units [][]string, numGroups int, conflicts [][]int, pins []int, bounds sizeBounds) ([][]string, error) {
+	search := &unitSearch{
+		ctx:        s.ctx,
+		deadline:   s.deadline,
+		units:      units,
+		conflicts:  conflicts,
+		numGroups:  numGroups,
+		minSize:    bounds.Min,
+		maxSize:    bounds.Max,
+		capacities: s.capacities(numGroups),
+		assignment: make([]int, len(units)),
+		groupSizes: make([]int, numGroups),
+		blocked:    make([][]int, len(units)),
+	}
+	for i := range units {
+		search.assignment[i] = -1
+		search.blocked[i] = make([]int, numGroups)
+		search.remaining += len(units[i])
+	}
+
+	// Empty groups stay interchangeable for the search, as all units fixed
+	// to a group are placed first.
+	placed := 0
+	for unit, group := range pins {
+		if group < 0 {
+			continue
+		}
+		if group >= numGroups || !search.fits(unit, group) {
+			return nil, nil
+		}
+		search.assign(unit, group)
+		placed++
+	}
+
+	found, err := search.place(placed)
+	s.debugf("Search with %d groups of %d to %d students visited %d nodes\n", numGroups, bounds.Min, bounds.Max, search.nodes)
+	if errors.Is(err, errTimeBudget) {
+		s.timedOut = true
+	}
+	if err != nil || !found {
+		return nil, err
+	}
+
+	groups := make([][]string, numGroups)
+	for unitIndex, groupIndex := range search.assignment {
+		groups[groupIndex] = append(groups[groupIndex], units[unitIndex]...)
+	}
+
+	return groups, nil
+}
+
+// unitConflicts lists, for every unit, the indexes of the units it cannot
+// share a group with according to the conflict function.
+func unitConflicts(units [][]string, conflict func(unit []string, otherUnit []string) bool) [][]int {
+	conflicts := make([][]int, len(units))
+	for i := range units {
+		for j := i + 1; j < len(units); j++ {
+			if conflict(units[i], units[j]) {
+				conflicts[i] = append(conflicts[i], j)
+				conflicts[j] = append(conflicts[j], i)
+			}
+		}
+	}
+
+	return conflicts
+}
+
+func (s *solver) unitsConflict(unit []string, otherUnit []string) bool {
+	for _, student := range unit {
+		for _, otherStudent := range otherUnit {
+			if studentsConflict(student, otherStudent, s.exclusionLookup) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (u *unitSearch) place(placed int) (bool, error) {
+	if u.deficit() > u.remaining {
+		return false, nil
+	}
+	if placed == len(u.units) {
+		return true, nil
+	}
+
+	u.nodes++
+	if u.nodes > searchNodeLimit {
+		return false, errSearchBudget
+	}
+	if u.nodes%1024 == 0 {
+		if err := u.ctx.Err(); err != nil {
+			return false, err
+		}
+		if time.Now().After(u.deadline) {
+			return false, errTimeBudget
+		}
+	}
+
+	unit := u.nextUnit()
+	if unit < 0 {
+		return false, nil
+	}
+
+	for _, group := range u.candidateGroups(unit) {
+		u.assign(unit, group)
+		found, err := u.place(placed + 1)
+		if found || err != nil {
+			return found, err
+		}
+		u.unassign(unit, group)
+	}
+
+	return false, nil
+}
+
+// nextUnit returns the unplaced unit with the fewest open groups, or -1 if
+// some unplaced unit has no open group left.
+func (u *unitSearch) nextUnit() int {
+	best, bestOpen := -1, 0
+	for unit, group := range u.assignment {
+		if group >= 0 {
+			continue
+		}
+
+		open := 0
+		for g := 0; g < u.numGroups; g++ {
+			if u.fits(unit, g) {
+				open++
+			}
+		}
+		if open == 0 {
+			return -1
+		}
+
+		if best < 0 || open < bestOpen ||
+			(open == bestOpen && len(u.units[unit]) > len(u.units[best])) ||
+			(open == bestOpen && len(u.units[unit]) == len(u.units[best]) && len(u.conflicts[unit]) > len(u.conflicts[best])) {
+			best, bestOpen = unit, open
+		}
+	}
+
+	return best
+}
+
+func (u *unitSearch) candidateGroups(unit int) []int {
+	candidates := make([]int, 0, u.numGroups)
+	var triedEmpty []int
+	for g := 0; g < u.numGroups; g++ {
+		if !u.fits(unit, g) {
+			continue
+		}
+		if u.groupSizes[g] == 0 {
+			capacity := 0
+			if u.capacities != nil {
+				capacity = u.capacities[g]
+			}
+			if slices.Contains(triedEmpty, capacity) {
+				continue
+			}
+			triedEmpty = append(triedEmpty, capacity)
+		}
+		candidates = append(candidates, g)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return u.groupSizes[candidates[i]] < u.groupSizes[candidates[j]]
+	})
+
+	return candidates
+}
+
+// fits reports whether unit can join group without a conflict and without
+// exceeding maxSize or the capacity of the group.
+func (u *unitSearch) fits(unit int, group int) bool {
+	if u.blocked[unit][group] > 0 {
+		return false
+	}
+
+	size := u.groupSizes[group] + len(u.units[unit])
+	if u.capacities != nil && u.capacities[group] > 0 && size > u.capacities[group] {
+		return false
+	}
+	return u.maxSize == 0 || size <= u.maxSize
+}
+
+// deficit is the number of students still missing to bring every group up to minSize.
+func (u *unitSearch) deficit() int {
+	deficit := 0
+	for _, size := range u.groupSizes {
+		if size < u.minSize {
+			deficit += u.minSize - size
+		}
+	}
+
+	return deficit
+}
+
+func (u *unitSearch) assign(unit int, group int) {
+	u.assignment[unit] = group
+	u.groupSizes[group] += len(u.units[unit])
+	u.remaining -= len(u.units[unit])
+	for _, other := range u.conflicts[unit] {
+		u.blocked[other][group]++
+	}
+}
+
+func (u *unitSearch) unassign(unit int, group int) {
+	u.assignment[unit] = -1
+	u.groupSizes[group] -= len(u.units[unit])
+	u.remaining += len(u.units[unit])
+	for _, other := range u.conflicts[unit] {
+		u.blocked[other][group]--
+	}
+}
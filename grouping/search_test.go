@@ -0,0 +1,37 @@
+package grouping
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+func TestGreedyFailsSearchSucceeds(t *testing.T) {
+	// Greedy placement puts A and B into different groups, which leaves no
+	// group for C, while A and B together leave the other group to C.
+	data := &types.GroupingData{
+		Students:   []string{"A", "B", "C", "D"},
+		Exclusions: [][]string{{"A", "C"}, {"B", "C"}},
+	}
+	opts := Options{Mode: ByCount, NumGroups: 2, Seed: 1}
+
+	s := newSolver(context.Background(), data, opts, 1)
+	units := [][]string{{"A"}, {"B"}, {"C"}, {"D"}}
+	s.unitPins = []int{-1, -1, -1, -1}
+	if groups, err := s.greedyNumGroups(units, 2); err == nil {
+		t.Fatalf("greedy placement found %v, want it to fail", groups)
+	}
+
+	for _, seed := range []int64{1, 2, 3, 4, 5} {
+		opts.Seed = seed
+		result, err := Solve(context.Background(), data, opts)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if len(result.Groups) != 2 {
+			t.Fatalf("seed %d: %d groups, want 2", seed, len(result.Groups))
+		}
+		checkGroups(t, data, result.Groups)
+	}
+}
@@ -0,0 +1,178 @@
+package grouping
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sizeLimits returns the group size limits from the options.
+func (s *solver) sizeLimits() sizeBounds {
+	return sizeBounds{Min: s.opts.MinSize, Max: s.opts.MaxSize}
+}
+
+func (s *solver) hasSizeLimits() bool {
+	return s.opts.MinSize > 0 || s.opts.MaxSize > 0
+}
+
+// withinSizeLimits reports whether every group respects MinSize, MaxSize
+// and its capacity.
+func (s *solver) withinSizeLimits(groups [][]string) bool {
+	for groupIndex, group := range groups {
+		if limit := s.groupLimit(groupIndex); len(group) < s.opts.MinSize || (limit > 0 && len(group) > limit) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// describeSizeLimits returns e.g. "groups of 3 to 5 students" for error messages.
+func describeSizeLimits(limits sizeBounds) string {
+	switch {
+	case limits.Min > 0 && limits.Min == limits.Max:
+		return "groups of exactly " + pluralize(limits.Min, "student")
+	case limits.Min > 0 && limits.Max > 0:
+		return fmt.Sprintf("groups of %d to %d students", limits.Min, limits.Max)
+	case limits.Min > 0:
+		return "groups of at least " + pluralize(limits.Min, "student")
+	default:
+		return "groups of at most " + pluralize(limits.Max, "student")
+	}
+}
+
+// validateSizeLimits checks MinSize and MaxSize against the data before any
+// search starts, so that impossible limits are reported with a reason
+// instead of as a failed search.
+func (s *solver) validateSizeLimits() error {
+	limits := s.sizeLimits()
+	if limits.Min < 0 || limits.Max < 0 {
+		return invalidInput("group size limits must not be negative")
+	}
+	if !s.hasSizeLimits() {
+		return nil
+	}
+	if limits.Max > 0 && limits.Min > limits.Max {
+		return infeasible("minimum group size %d is larger than the maximum group size %d", limits.Min, limits.Max)
+	}
+
+	if limits.Max > 0 {
+		for _, inclusionGroup := range s.data.Inclusions {
+			if len(inclusionGroup) > limits.Max {
+				return infeasible("maximum group size %d cannot be met: students %s are required to be together", limits.Max, quoteNames(inclusionGroup))
+			}
+		}
+	}
+
+	total := s.studentCount()
+	if s.opts.Mode.countsGroups() {
+		numGroups := s.opts.NumGroups
+		if limits.Min*numGroups > total {
+			return infeasible("minimum group size %d cannot be met with %s in %s", limits.Min, pluralize(total, "student"), pluralize(numGroups, "group"))
+		}
+		if limits.Max > 0 && limits.Max*numGroups < total {
+			return infeasible("maximum group size %d cannot be met with %s in %s", limits.Max, pluralize(total, "student"), pluralize(numGroups, "group"))
+		}
+		return nil
+	}
+
+	subjects := len(s.data.SubjectStudents)
+	if limits.Min > subjects {
+		return infeasible("minimum group size %d cannot be met: with one student per subject a group holds at most %s", limits.Min, pluralize(subjects, "student"))
+	}
+
+	// Students from the largest subject or exclusion group all need
+	// different groups, but the minimum size limits the number of groups.
+	minGroups, maxGroups := s.subjectGroupCountRange()
+	if limits.Min > 0 {
+		clique, description := s.largestMutuallyExcluded()
+		if clique > maxGroups {
+			return infeasible("minimum group size %d cannot be met: %s must all be in different groups, but with %s there can be at most %s of at least %d", limits.Min, description, pluralize(total, "student"), pluralize(maxGroups, "group"), limits.Min)
+		}
+	}
+	if minGroups > maxGroups {
+		return infeasible("%s cannot be met: %s cannot be split into groups of that size", describeSizeLimits(limits), pluralize(total, "student"))
+	}
+
+	return nil
+}
+
+// subjectGroupCountRange returns the smallest and largest number of groups
+// that the subject sizes and the size limits allow in BySubject mode.
+func (s *solver) subjectGroupCountRange() (int, int) {
+	total := s.studentCount()
+
+	minGroups := 1
+	for _, students := range s.data.SubjectStudents {
+		minGroups = max(minGroups, len(students))
+	}
+	if s.opts.MaxSize > 0 {
+		minGroups = max(minGroups, (total+s.opts.MaxSize-1)/s.opts.MaxSize)
+	}
+
+	maxGroups := total
+	if s.opts.MinSize > 0 {
+		maxGroups = total / s.opts.MinSize
+	}
+
+	return minGroups, maxGroups
+}
+
+// largestMutuallyExcluded returns the size of the largest subject or
+// exclusion group, whose students must all end up in different groups,
+// together with a description for error messages.
+func (s *solver) largestMutuallyExcluded() (int, string) {
+	size, description := 0, ""
+
+	subjects := make([]string, 0, len(s.data.SubjectStudents))
+	for subject := range s.data.SubjectStudents {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+	for _, subject := range subjects {
+		if students := s.data.SubjectStudents[subject]; len(students) > size {
+			size = len(students)
+			description = fmt.Sprintf("the %s of subject %q", pluralize(size, "student"), subject)
+		}
+	}
+
+	for _, exclusionGroup := range s.data.Exclusions {
+		if len(exclusionGroup) > size {
+			size = len(exclusionGroup)
+			description = fmt.Sprintf("the %d mutually excluded students %s", size, quoteNames(exclusionGroup))
+		}
+	}
+
+	return size, description
+}
+
+func (s *solver) studentCount() int {
+	if s.opts.Mode.countsGroups() {
+		return len(s.data.Students)
+	}
+
+	total := 0
+	for _, students := range s.data.SubjectStudents {
+		total += len(students)
+	}
+
+	return total
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// pluralize returns e.g. "1 group" or "3 groups".
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+
+	return fmt.Sprintf("%d %ss", count, noun)
+}
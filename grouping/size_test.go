@@ -0,0 +1,70 @@
+package grouping
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kremec/edugroup/types"
+)
+
+func TestDescribeSizeLimits(t *testing.T) {
+	tests := []struct {
+		limits sizeBounds
+		want   string
+	}{
+		{sizeBounds{Min: 1, Max: 1}, "groups of exactly 1 student"},
+		{sizeBounds{Min: 3, Max: 3}, "groups of exactly 3 students"},
+		{sizeBounds{Min: 2, Max: 4}, "groups of 2 to 4 students"},
+		{sizeBounds{Min: 1}, "groups of at least 1 student"},
+		{sizeBounds{Max: 1}, "groups of at most 1 student"},
+		{sizeBounds{Max: 5}, "groups of at most 5 students"},
+	}
+
+	for _, test := range tests {
+		if got := describeSizeLimits(test.limits); got != test.want {
+			t.Errorf("describeSizeLimits(%+v) = %q, want %q", test.limits, got, test.want)
+		}
+	}
+}
+
+func TestImpossibleSizeLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		data *types.GroupingData
+		opts Options
+		want string
+	}{
+		{
+			name: "maximum size",
+			data: &types.GroupingData{Students: []string{"A", "B", "C"}},
+			opts: Options{Mode: ByCount, NumGroups: 2, MaxSize: 1},
+			want: "maximum group size 1 cannot be met with 3 students in 2 groups",
+		},
+		{
+			name: "minimum size",
+			data: &types.GroupingData{Students: []string{"A"}},
+			opts: Options{Mode: ByCount, NumGroups: 1, MinSize: 2},
+			want: "minimum group size 2 cannot be met with 1 student in 1 group",
+		},
+		{
+			name: "one subject",
+			data: &types.GroupingData{Subjects: []string{"Math"}, SubjectStudents: map[string][]string{"Math": {"A", "B"}}},
+			opts: Options{Mode: BySubject, MinSize: 2},
+			want: "minimum group size 2 cannot be met: with one student per subject a group holds at most 1 student",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Solve(context.Background(), test.data, test.opts)
+			var infeasibleErr *InfeasibleError
+			if !errors.As(err, &infeasibleErr) {
+				t.Fatalf("got %v, want an InfeasibleError", err)
+			}
+			if infeasibleErr.Msg != test.want {
+				t.Errorf("got %q, want %q", infeasibleErr.Msg, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,492 @@
+package grouping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/kremec/edugroup/types"
+)
+
+type solver struct {
+	ctx             context.Context
+	data            *types.GroupingData
+	opts            Options
+	rng             *rand.Rand
+	log             io.Writer
+	exclusionLookup map[string]map[string]struct{}
+	deadline        time.Time
+	// units, unitConflicts and unitPins are set by the mode-specific
+	// grouping and reused by the optimizer. unitPins holds the group every
+	// unit is fixed to, or -1.
+	units         [][]string
+	unitConflicts [][]int
+	unitPins      []int
+	// earlier penalizes pairs of students grouped together in the
+	// alternatives found before, and is nil outside SolveAlternatives.
+	earlier *historyTerm
+	// timedOut is set when the time budget, rather than a limit on the
+	// work, cut a search short, so the seed may not repeat the result.
+	timedOut    bool
+	diagnostics Diagnostics
+}
+
+func (s *solver) debugf(format string, args ...any) {
+	if s.log != nil {
+		fmt.Fprintf(s.log, format, args...)
+	}
+}
+
+func (s *solver) note(format string, args ...any) {
+	s.diagnostics.Notes = append(s.diagnostics.Notes, fmt.Sprintf(format, args...))
+}
+
+// createSubjectGroups creates student groups based on the subjects and exclusions data.
+func (s *solver) createSubjectGroups() ([][]string, error) {
+	groups := [][]string{}
+
+	// Map students to corresponding subjects
+	studentSubject := make(map[string]string)
+	for subject, students := range s.data.SubjectStudents {
+		for _, student := range students {
+			if student != "" {
+				studentSubject[student] = subject
+			}
+		}
+	}
+
+	if err := s.validateInclusionsAgainstExclusions(); err != nil {
+		return nil, err
+	}
+	if err := validateSubjectInclusions(s.data.Inclusions, studentSubject); err != nil {
+		return nil, err
+	}
+	if err := s.validateAssignments(studentSubject); err != nil {
+		return nil, err
+	}
+
+	allStudents := flattenSubjectStudentsBySubject(s.data)
+	units := s.buildAssignmentUnits(allStudents)
+	s.unitPins, _ = unitPins(units, s.data.Assignments)
+
+	// Students from the same subject are treated as excluded from each other.
+	s.units = units
+	s.unitConflicts = unitConflicts(units, func(unit []string, otherUnit []string) bool {
+		for _, student := range unit {
+			for _, otherStudent := range otherUnit {
+				if studentSubject[student] == studentSubject[otherStudent] {
+					return true
+				}
+			}
+		}
+		return s.unitsConflict(unit, otherUnit)
+	})
+
+	canAddUnitToGroup := func(unit []string, group []string) bool {
+		for _, student := range unit {
+			for _, studentInGroup := range group {
+				// Dissallow students from the same subject
+				if studentSubject[studentInGroup] == studentSubject[student] {
+					return false
+				}
+
+				if studentsConflict(student, studentInGroup, s.exclusionLookup) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	processUnit := func(unit []string) {
+		// Fill the groups left empty before a group students are fixed to
+		for groupIndex, group := range groups {
+			if len(group) == 0 {
+				s.debugf("Filling empty group %d with %v\n", groupIndex+1, unit)
+				groups[groupIndex] = slices.Clone(unit)
+				return
+			}
+		}
+
+		// Add student to existing groups if possible
+		for groupIndex, group := range groups {
+			if canAddUnitToGroup(unit, group) {
+				s.debugf("Adding %v to group %s\n", unit, group)
+				groups[groupIndex] = append(group, unit...)
+				return
+			}
+		}
+
+		// Else create a new group
+		s.debugf("Creating new group for %v\n", unit)
+		groups = append(groups, slices.Clone(unit))
+	}
+
+	// Units fixed to a group go there first, into as many groups as that
+	// takes.
+	groups = make([][]string, s.lastPinnedGroup()+1)
+	for unitIndex, unit := range units {
+		if group := s.unitPins[unitIndex]; group >= 0 {
+			s.debugf("Fixing %v to group %d\n", unit, group+1)
+			groups[group] = append(groups[group], unit...)
+		}
+	}
+
+	// Process inclusion groups and constrained students first
+	for unitIndex, unit := range units {
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if s.unitPins[unitIndex] >= 0 {
+			continue
+		}
+		s.debugf("Processing: %v\n", unit)
+		processUnit(unit)
+		s.debugf("Current groups: %v\n\n", groups)
+	}
+
+	s.debugf("Final groups: %v\n", groups)
+
+	for groupIndex, group := range groups {
+		if len(group) == 0 {
+			return nil, infeasible("group %d would be empty: students are fixed to group %d, but there are too few students for %s", groupIndex+1, len(groups), pluralize(len(groups), "group"))
+		}
+	}
+
+	if s.opts.Balance || s.hasSizeLimits() {
+		return s.searchSubjectGroups(groups)
+	}
+
+	return groups, nil
+}
+
+// searchSubjectGroups redistributes the units of the first-fit groups to
+// honour Options.Balance and the group size limits. If the search runs out of
+// time, the first-fit groups are kept as long as they respect the size limits.
+func (s *solver) searchSubjectGroups(firstFit [][]string) ([][]string, error) {
+	minGroups, maxGroups := s.subjectGroupCountRange()
+	minGroups = max(minGroups, s.lastPinnedGroup()+1)
+	if !s.hasSizeLimits() {
+		// First-fit already found a grouping with this many groups.
+		maxGroups = len(firstFit)
+	}
+
+	groups, err := s.rangeSearch(s.units, s.unitConflicts, s.unitPins, minGroups, maxGroups)
+	if groups != nil {
+		s.debugf("Searched groups: %v\n", groups)
+		if s.opts.Balance {
+			s.noteSizeSpread(groups)
+		}
+		return groups, nil
+	}
+	if err == nil {
+		return nil, infeasible("exception and inclusion constraints cannot be met with %s", describeSizeLimits(s.sizeLimits()))
+	}
+	if !errors.Is(err, errSearchBudget) {
+		return nil, err
+	}
+
+	if !s.withinSizeLimits(firstFit) {
+		return nil, fmt.Errorf("no grouping with %s was found within the search budget", describeSizeLimits(s.sizeLimits()))
+	}
+	s.note("search budget exhausted, keeping first-fit groups")
+
+	return firstFit, nil
+}
+
+// createNumGroups creates student groups based on the number of groups.
+// A complete backtracking search is tried first; if it runs out of time the
+// greedy placement is used instead.
+func (s *solver) createNumGroups(numGroups int) ([][]string, error) {
+	if err := s.validateInclusionsAgainstExclusions(); err != nil {
+		return nil, err
+	}
+	if err := s.validateAssignments(nil); err != nil {
+		return nil, err
+	}
+
+	units := s.buildAssignmentUnits(s.data.Students)
+
+	conflicts := unitConflicts(units, s.unitsConflict)
+	s.units = units
+	s.unitConflicts = conflicts
+	s.unitPins, _ = unitPins(units, s.data.Assignments)
+	if s.opts.Mode == ByPreference && !s.opts.Balance {
+		if groups := s.rankedGroups(numGroups); groups != nil {
+			return groups, nil
+		}
+	}
+
+	var groups [][]string
+	var err error
+	if s.opts.Balance {
+		groups, err = s.balancedSearch(units, conflicts, s.unitPins, numGroups, numGroups)
+		s.noteSizeSpread(groups)
+	} else {
+		groups, err = s.searchGroups(units, numGroups, conflicts, s.unitPins, s.sizeLimits())
+	}
+	if groups != nil {
+		return groups, nil
+	}
+	if err == nil {
+		return nil, s.numGroupsInfeasible(numGroups)
+	}
+	if !errors.Is(err, errSearchBudget) {
+		return nil, err
+	}
+
+	s.note("search budget exhausted, falling back to greedy placement")
+	s.debugf("Search budget exhausted, falling back to greedy placement\n")
+
+	groups, err = s.greedyNumGroups(units, numGroups)
+	if err == nil && !s.withinSizeLimits(groups) {
+		return nil, fmt.Errorf("no grouping with %s was found within the search budget", describeSizeLimits(s.sizeLimits()))
+	}
+
+	return groups, err
+}
+
+// greedyNumGroups places every unit into the smallest group it does not
+// conflict with, after the units fixed to a group.
+func (s *solver) greedyNumGroups(units [][]string, numGroups int) ([][]string, error) {
+	groups := make([][]string, numGroups)
+	order := make([]int, numGroups)
+	for i := range order {
+		order[i] = i
+	}
+
+	canAddUnitToGroup := func(unit []string, group []string) bool {
+		for _, student := range unit {
+			for _, studentInGroup := range group {
+				if studentsConflict(student, studentInGroup, s.exclusionLookup) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	processUnit := func(unit []string) error {
+
+		// Add student to existing groups if possible. Groups keep their
+		// place, since students may be fixed to them.
+		sort.SliceStable(order, func(i, j int) bool {
+			return len(groups[order[i]]) < len(groups[order[j]])
+		})
+		for _, groupIndex := range order {
+			if limit := s.groupLimit(groupIndex); limit > 0 && len(groups[groupIndex])+len(unit) > limit {
+				continue
+			}
+			if canAddUnitToGroup(unit, groups[groupIndex]) {
+				s.debugf("Adding %v to group %s\n", unit, groups[groupIndex])
+				groups[groupIndex] = append(groups[groupIndex], unit...)
+				return nil
+			}
+		}
+		return infeasible("exception and inclusion constraints cannot be met for this number of groups")
+	}
+
+	for unitIndex, unit := range units {
+		if group := s.unitPins[unitIndex]; group >= 0 {
+			groups[group] = append(groups[group], unit...)
+		}
+	}
+
+	// Process inclusion groups and constrained students first
+	for unitIndex, unit := range units {
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if s.unitPins[unitIndex] >= 0 {
+			continue
+		}
+		s.debugf("Processing: %v\n", unit)
+		if err := processUnit(unit); err != nil {
+			return nil, err
+		}
+		s.debugf("Current groups: %v\n\n", groups)
+	}
+
+	s.debugf("Final groups: %v\n", groups)
+
+	return groups, nil
+}
+
+func buildExclusionLookup(exclusions [][]string) map[string]map[string]struct{} {
+	lookup := make(map[string]map[string]struct{})
+
+	for _, exclusionGroup := range exclusions {
+		for _, student := range exclusionGroup {
+			if lookup[student] == nil {
+				lookup[student] = make(map[string]struct{})
+			}
+
+			for _, otherStudent := range exclusionGroup {
+				if otherStudent == student {
+					continue
+				}
+				lookup[student][otherStudent] = struct{}{}
+			}
+		}
+	}
+
+	return lookup
+}
+
+func validateSubjectInclusions(inclusions [][]string, studentSubject map[string]string) error {
+	for _, inclusionGroup := range inclusions {
+		seenSubjects := make(map[string]string)
+		for _, student := range inclusionGroup {
+			subject := studentSubject[student]
+			if firstStudent, exists := seenSubjects[subject]; exists {
+				return infeasible("students %q and %q are required to be together but both belong to subject %q", firstStudent, student, subject)
+			}
+			seenSubjects[subject] = student
+		}
+	}
+
+	return nil
+}
+
+func (s *solver) validateInclusionsAgainstExclusions() error {
+	for inclusionIndex, inclusionGroup := range s.data.Inclusions {
+		for i := 0; i < len(inclusionGroup); i++ {
+			for j := i + 1; j < len(inclusionGroup); j++ {
+				if !studentsConflict(inclusionGroup[i], inclusionGroup[j], s.exclusionLookup) {
+					continue
+				}
+
+				pair := []string{inclusionGroup[i], inclusionGroup[j]}
+				err := infeasible("students %q and %q are required to be together but are also listed in an exclusion group", pair[0], pair[1])
+				err.Conflict = append(err.Conflict, ConflictingConstraint{
+					Kind:     InclusionConstraint,
+					Index:    inclusionIndex,
+					Students: pair,
+					Cells:    s.constraintCells(InclusionConstraint, inclusionIndex, pair),
+				})
+				for exclusionIndex, exclusionGroup := range s.data.Exclusions {
+					if slices.Contains(exclusionGroup, pair[0]) && slices.Contains(exclusionGroup, pair[1]) {
+						err.Conflict = append(err.Conflict, ConflictingConstraint{
+							Kind:     ExclusionConstraint,
+							Index:    exclusionIndex,
+							Students: pair,
+							Cells:    s.constraintCells(ExclusionConstraint, exclusionIndex, pair),
+						})
+						break
+					}
+				}
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// numGroupsInfeasible explains why the students cannot be split into
+// numGroups groups.
+func (s *solver) numGroupsInfeasible(numGroups int) error {
+	msg := "exception and inclusion constraints cannot be met for this number of groups"
+	if len(s.data.Assignments) > 0 {
+		msg = "exception, inclusion and fixed assignment constraints cannot be met for this number of groups"
+	}
+	if s.hasSizeLimits() {
+		msg += " with " + describeSizeLimits(s.sizeLimits())
+	}
+
+	conflict := s.explainNumGroups(numGroups)
+	if description := describeConflict(conflict, numGroups); description != "" {
+		msg += ": " + description
+	}
+
+	return &InfeasibleError{Msg: msg, Conflict: conflict}
+}
+
+func (s *solver) buildAssignmentUnits(students []string) [][]string {
+	units := make([][]string, 0, len(students))
+	includedStudents := make(map[string]struct{}, len(students))
+
+	for _, inclusionGroup := range s.data.Inclusions {
+		unit := slices.Clone(inclusionGroup)
+		units = append(units, unit)
+		for _, student := range inclusionGroup {
+			includedStudents[student] = struct{}{}
+		}
+	}
+
+	for _, student := range students {
+		if _, exists := includedStudents[student]; exists {
+			continue
+		}
+		units = append(units, []string{student})
+	}
+
+	s.rng.Shuffle(len(units), func(i, j int) {
+		units[i], units[j] = units[j], units[i]
+	})
+
+	sort.SliceStable(units, func(i, j int) bool {
+		iHasConstraints := unitHasExclusions(units[i], s.exclusionLookup)
+		jHasConstraints := unitHasExclusions(units[j], s.exclusionLookup)
+		if iHasConstraints != jHasConstraints {
+			return iHasConstraints
+		}
+
+		if len(units[i]) != len(units[j]) {
+			return len(units[i]) > len(units[j])
+		}
+
+		return false
+	})
+
+	s.diagnostics.Units = len(units)
+	s.diagnostics.ConstrainedUnits = 0
+	for _, unit := range units {
+		if unitHasExclusions(unit, s.exclusionLookup) {
+			s.diagnostics.ConstrainedUnits++
+		}
+	}
+
+	return units
+}
+
+func unitHasExclusions(unit []string, exclusionLookup map[string]map[string]struct{}) bool {
+	for _, student := range unit {
+		if len(exclusionLookup[student]) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func studentsConflict(student string, otherStudent string, exclusionLookup map[string]map[string]struct{}) bool {
+	_, exists := exclusionLookup[student][otherStudent]
+	return exists
+}
+
+// flattenSubjectStudentsBySubject lists the students subject by subject, in
+// the order of data.Subjects followed by any remaining subjects sorted by name.
+func flattenSubjectStudentsBySubject(data *types.GroupingData) []string {
+	subjects := slices.Clone(data.Subjects)
+	remaining := make([]string, 0)
+	for subject := range data.SubjectStudents {
+		if !slices.Contains(subjects, subject) {
+			remaining = append(remaining, subject)
+		}
+	}
+	sort.Strings(remaining)
+	subjects = append(subjects, remaining...)
+
+	students := make([]string, 0)
+	for _, subject := range subjects {
+		students = append(students, data.SubjectStudents[subject]...)
+	}
+
+	return students
+}
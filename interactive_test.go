@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/internal/excel"
+)
+
+func TestRunInteractivePipedInput(t *testing.T) {
+	// Without a desktop every answer, including the file paths, is read from
+	// standard input.
+	t.Setenv("DISPLAY", "")
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	output := filepath.Join(t.TempDir(), "groups.xlsx")
+	stdin, answers, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	if _, err := answers.WriteString("3\n\nexamples/by-numgroups_in.xlsx\n" + output + "\n\n"); err != nil {
+		t.Fatal(err)
+	}
+	answers.Close()
+
+	opts := cliOptions{weights: grouping.DefaultWeights(), timeBudget: grouping.DefaultTimeBudget, alternatives: 1}
+	runInteractive(opts, bufio.NewReader(stdin))
+
+	history, err := excel.ReadHistory(output)
+	if err != nil {
+		t.Fatalf("no groups were saved: %v", err)
+	}
+	if rounds := history.Rounds(); rounds != 1 {
+		t.Errorf("%s holds %d groupings, want 1", output, rounds)
+	}
+}
@@ -1,27 +1,139 @@
 package dialogs
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
-
-	"github.com/sqweek/dialog"
 )
 
-func OpenExcelFile() (string, error) {
-	filename, err := dialog.File().Title("Open Excel file").Filter("Excel files", "xlsx").Filter("All files", "*").Load()
+// ErrNoGUI is returned by OpenFile when there is no desktop to show the file
+// on.
+var ErrNoGUI = errors.New("no graphical desktop available")
+
+// OpenExcelFile asks for the Excel file to group, with a file dialog or, when
+// there is no desktop, on the console, read through the reader the caller
+// reads the rest of standard input with.
+func OpenExcelFile(console *bufio.Reader) (string, error) {
+	if guiAvailable() {
+		filename, err := loadFile("Open Excel file")
+		if !useConsole(err) {
+			return filename, err
+		}
+	}
+
+	filename, err := promptPath(console, "Path of the Excel file to group: ", "")
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filename); err != nil {
+		return "", err
+	}
 
-	return filename, err
+	return filename, nil
 }
 
-func SaveExcelFile(filename string) (string, error) {
-	filename, err := dialog.File().Title("Save Excel file").Filter("Excel files", "xlsx").Filter("All files", "*").Save()
+// SaveExcelFile asks where to save the groups made from inputFile, with a file
+// dialog or, when there is no desktop, on the console like OpenExcelFile.
+func SaveExcelFile(console *bufio.Reader, inputFile string) (string, error) {
+	suggested := strings.TrimSuffix(inputFile, filepath.Ext(inputFile)) + "_groups.xlsx"
+
+	if guiAvailable() {
+		filename, err := saveFile("Save Excel file", suggested)
+		if !useConsole(err) {
+			return withExcelExtension(filename), err
+		}
+	}
+
+	filename, err := promptPath(console, "Path to save the groups to ["+suggested+"]: ", suggested)
+	return withExcelExtension(filename), err
+}
 
+func withExcelExtension(filename string) string {
 	if !strings.HasSuffix(filename, ".xlsx") {
 		filename += ".xlsx"
 	}
 
-	return filename, err
+	return filename
 }
 
+// ShowErrorDialog shows err in a message box, or prints it to the console
+// when there is no desktop.
 func ShowErrorDialog(err error) {
-	dialog.Message("%s", err).Title("Error").Error()
+	if guiAvailable() {
+		showError(err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+}
+
+// OpenFile opens path in the default application for its type.
+func OpenFile(path string) error {
+	if !guiAvailable() {
+		return ErrNoGUI
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		// The empty title keeps start from taking a quoted path for the
+		// window title.
+		cmd = exec.Command("cmd", "/c", "start", "", path)
+	case "darwin":
+		cmd = exec.Command("open", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	go cmd.Wait()
+
+	return nil
+}
+
+// guiAvailable tells whether dialogs can be shown. Windows and macOS always
+// have a desktop, other systems only when a display server is set.
+func guiAvailable() bool {
+	if !guiSupported {
+		return false
+	}
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return true
+	}
+
+	return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// useConsole tells whether a dialog failed in a way that the console can
+// stand in for, rather than being cancelled by the user.
+func useConsole(err error) bool {
+	return err != nil && !errors.Is(err, errCancelled)
+}
+
+// promptPath reads a path from the console, or returns fallback for an empty
+// line. Quotes around the path, as added by "Copy as path", are removed. The
+// last line of piped input may lack its line break.
+func promptPath(console *bufio.Reader, prompt string, fallback string) (string, error) {
+	fmt.Print(prompt)
+	line, err := console.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	path := strings.Trim(strings.TrimSpace(line), `"'`)
+	if path == "" {
+		if fallback == "" {
+			return "", errors.New("no file given")
+		}
+		return fallback, nil
+	}
+
+	return path, nil
 }
@@ -0,0 +1,35 @@
+package dialogs
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestPromptPathSharesConsole(t *testing.T) {
+	// Every prompt reads one line from the same reader, so piped answers
+	// are not buffered away from the prompts after it.
+	console := bufio.NewReader(strings.NewReader("groups.xlsx\n\n\"C:\\Class 3A\\groups.xlsx\""))
+
+	tests := []struct {
+		fallback string
+		want     string
+	}{
+		{fallback: "", want: "groups.xlsx"},
+		{fallback: "suggested.xlsx", want: "suggested.xlsx"},
+		{fallback: "", want: `C:\Class 3A\groups.xlsx`},
+	}
+	for i, test := range tests {
+		got, err := promptPath(console, "", test.fallback)
+		if err != nil {
+			t.Fatalf("prompt %d: %v", i+1, err)
+		}
+		if got != test.want {
+			t.Errorf("prompt %d read %q, want %q", i+1, got, test.want)
+		}
+	}
+
+	if _, err := promptPath(console, "", ""); err == nil {
+		t.Error("prompt after the last line succeeded, want an error")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !nogui
+
+package dialogs
+
+import "github.com/sqweek/dialog"
+
+const guiSupported = true
+
+var errCancelled = dialog.ErrCancelled
+
+func loadFile(title string) (string, error) {
+	return dialog.File().Title(title).Filter("Excel files", "xlsx").Filter("All files", "*").Load()
+}
+
+func saveFile(title string, startFile string) (string, error) {
+	return dialog.File().Title(title).Filter("Excel files", "xlsx").Filter("All files", "*").SetStartFile(startFile).Save()
+}
+
+func showError(err error) {
+	dialog.Message("%s", err).Title("Error").Error()
+}
@@ -0,0 +1,21 @@
+//go:build nogui
+
+package dialogs
+
+import "errors"
+
+// Built with the nogui tag, e.g. for servers without GTK, paths are always
+// asked for on the console.
+const guiSupported = false
+
+var errCancelled = errors.New("cancelled")
+
+func loadFile(title string) (string, error) {
+	return "", ErrNoGUI
+}
+
+func saveFile(title string, startFile string) (string, error) {
+	return "", ErrNoGUI
+}
+
+func showError(err error) {}
@@ -0,0 +1,128 @@
+package excel
+
+import (
+	"strconv"
+	"strings"
+)
+
+// getAssignments reads the fixed assignments sheet: a student name in column
+// A and the group they must be in in column B, as a group number such as 2
+// or a group name such as "Group 2" or one of names, the names of the named
+// groups. A first row whose column B holds no group is a header row. It
+// returns the zero-based group index of every fixed student together with
+// the cell of their name.
+func getAssignments(f sheetSource, knownStudents []string, names []string) (map[string]int, map[string]string, error) {
+	assignments := make(map[string]int)
+	cells := make(map[string]string)
+
+	// If the sheet is missing, no student is fixed to a group.
+	if !f.hasSheet(assignmentsSheet) {
+		return assignments, cells, nil
+	}
+
+	rows, err := f.getRows(assignmentsSheet)
+	if err != nil {
+		return nil, nil, sheetError(err)
+	}
+
+	issues := &validationErrors{}
+	seen := make(map[string]string)
+	knownStudentsNormalized := make(map[string]string, len(knownStudents))
+	for _, student := range knownStudents {
+		knownStudentsNormalized[strings.ToLower(student)] = student
+	}
+
+	for rowIndex, row := range rows {
+		rawName, rawGroup := cellValue(row, 0), cellValue(row, 1)
+		name, group := trimmedValue(rawName), trimmedValue(rawGroup)
+		if rowIndex == 0 {
+			if _, isGroup := parseGroupRef(group, names); !isGroup {
+				continue
+			}
+		}
+
+		nameCell := cellReference(f, assignmentsSheet, 0, rowIndex)
+		groupCell := cellReference(f, assignmentsSheet, 1, rowIndex)
+		for colIndex := 2; colIndex < len(row); colIndex++ {
+			if trimmedValue(row[colIndex]) != "" {
+				issues.add("%s contains %q, but fixed assignments only take a student name in %s and a group in %s", cellReference(f, assignmentsSheet, colIndex, rowIndex), row[colIndex], columnOf(nameCell), columnOf(groupCell))
+			}
+		}
+		if rawName != name {
+			issues.add("fixed assignment name at %s contains leading or trailing spaces", nameCell)
+		}
+
+		switch {
+		case name == "" && group == "":
+			continue
+		case name == "":
+			issues.add("%s fixes nobody to group %q; add the student's name in %s", rowOf(groupCell), group, nameCell)
+			continue
+		}
+
+		canonicalName, exists := knownStudentsNormalized[strings.ToLower(name)]
+		switch {
+		case !exists:
+			issues.add("fixed assignment name %q at %s does not match any student from the students sheet", name, nameCell)
+			continue
+		case canonicalName != name:
+			issues.add("fixed assignment name %q at %s must match the students-sheet name exactly: %q", name, nameCell, canonicalName)
+			continue
+		}
+
+		groupIndex, isGroup := parseGroupRef(group, names)
+		if !isGroup {
+			example := groupName(1)
+			if len(names) > 0 {
+				example = names[0]
+			}
+			issues.add("group %q of student %q at %s must be a group number such as 2 or a group name such as %q", group, name, groupCell, example)
+			continue
+		}
+		if !groupInRange(groupIndex, names) {
+			issues.add("group %d of student %q at %s does not exist; there are only %d named groups", groupIndex+1, name, groupCell, len(names))
+			continue
+		}
+
+		if first, exists := seen[name]; exists {
+			issues.add("student %q is fixed to a group twice, at %s and %s", name, first, nameCell)
+			continue
+		}
+
+		seen[name] = nameCell
+		assignments[name] = groupIndex
+		cells[name] = cellReference(f, assignmentsSheet, 0, rowIndex)
+	}
+
+	if err := issues.err(); err != nil {
+		return nil, nil, err
+	}
+
+	return assignments, cells, nil
+}
+
+// parseGroupRef returns the zero-based index of the group given by its
+// number, by its name as written to the groups sheet, or by one of names,
+// ignoring case. Numbers above the named groups are checked by groupInRange.
+func parseGroupRef(value string, names []string) (int, bool) {
+	for index, name := range names {
+		if strings.EqualFold(strings.TrimSpace(value), name) {
+			return index, true
+		}
+	}
+
+	value = strings.TrimSpace(strings.TrimPrefix(strings.ToLower(value), strings.ToLower(groupHeader)))
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, false
+	}
+
+	return number - 1, true
+}
+
+// groupInRange reports whether the group index returned by parseGroupRef
+// is one of names. Without names the number of groups is not known while
+// reading, so every index is in range.
+func groupInRange(groupIndex int, names []string) bool {
+	return len(names) == 0 || groupIndex < len(names)
+}
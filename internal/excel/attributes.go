@@ -0,0 +1,151 @@
+package excel
+
+import (
+	"strings"
+
+	"github.com/kremec/edugroup/grouping"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// studentAttributes collects the attribute columns of the students sheet.
+// Attribute columns are marked by a header in square brackets, e.g. "[Gender]".
+type studentAttributes struct {
+	names  []string
+	values map[string]map[string]string
+}
+
+// attributeColumn is an attribute column of the students sheet. nameColIndex is
+// the column with the names of the students the values belong to.
+type attributeColumn struct {
+	colIndex     int
+	name         string
+	nameColIndex int
+}
+
+func newStudentAttributes() *studentAttributes {
+	return &studentAttributes{
+		names:  make([]string, 0),
+		values: make(map[string]map[string]string),
+	}
+}
+
+func (a *studentAttributes) addName(name string) {
+	for _, existing := range a.names {
+		if existing == name {
+			return
+		}
+	}
+	a.names = append(a.names, name)
+}
+
+func (a *studentAttributes) set(student string, name string, value string) {
+	if a.values[student] == nil {
+		a.values[student] = make(map[string]string)
+	}
+	a.values[student][name] = value
+}
+
+// attributeHeader returns the attribute name from a header like "[Gender]".
+func attributeHeader(header string) (string, bool) {
+	header = trimmedValue(header)
+	if !strings.HasPrefix(header, "[") || !strings.HasSuffix(header, "]") {
+		return "", false
+	}
+
+	return trimmedValue(header[1 : len(header)-1]), true
+}
+
+// readAttributeValue validates the attribute value at (colIndex, rowIndex)
+// and stores it for student.
+func readAttributeValue(f sheetSource, rows [][]string, colIndex int, rowIndex int, student string, name string, attributes *studentAttributes, issues *validationErrors) {
+	if colIndex >= len(rows[rowIndex]) {
+		return
+	}
+
+	cell := cellReference(f, rosterSheet, colIndex, rowIndex)
+	rawValue := rows[rowIndex][colIndex]
+	value := trimmedValue(rawValue)
+	if rawValue != "" && rawValue != value {
+		issues.add("%s value at %s contains leading or trailing spaces", name, cell)
+	}
+	if value == "" {
+		return
+	}
+
+	if student == "" {
+		issues.add("%s value %q at %s has no student name next to it", name, value, cell)
+		return
+	}
+
+	attributes.set(student, name, value)
+}
+
+// getAttributeColumns returns the attribute columns right of column A in the
+// header row of the number-of-groups roster. Once any of them is found, row 1
+// is a header row and every other header next to column A must be an
+// attribute too.
+func getAttributeColumns(f sheetSource, header []string, issues *validationErrors) []attributeColumn {
+	columns := make([]attributeColumn, 0)
+	otherHeaders := make([]string, 0)
+	seen := make(map[string]string)
+	for colIndex := 1; colIndex < len(header); colIndex++ {
+		cell := cellReference(f, rosterSheet, colIndex, 0)
+		name, ok := attributeHeader(header[colIndex])
+		if !ok {
+			if trimmedValue(header[colIndex]) != "" {
+				otherHeaders = append(otherHeaders, cell)
+			}
+			continue
+		}
+
+		if name == "" {
+			issues.add("attribute header at %s is empty", cell)
+			continue
+		}
+		if first, exists := seen[name]; exists {
+			issues.add("attribute %q is duplicated at %s and %s", name, first, cell)
+			continue
+		}
+		seen[name] = cell
+		columns = append(columns, attributeColumn{colIndex: colIndex, name: name, nameColIndex: 0})
+	}
+
+	if len(columns) > 0 {
+		for _, cell := range otherHeaders {
+			issues.add("header %s must be an attribute name in square brackets, e.g. \"[Gender]\"", cell)
+		}
+	}
+
+	return columns
+}
+
+// writeAttributesSheet writes one table per attribute, with a row per group
+// and a column per attribute value.
+func writeAttributesSheet(f *excelize.File, distribution []grouping.AttributeDistribution, names []string) error {
+	if _, err := f.NewSheet(attributesSheetName); err != nil {
+		return err
+	}
+
+	rowIndex := 0
+	for _, attribute := range distribution {
+		f.SetCellValue(attributesSheetName, spreadsheetCell(0, rowIndex), attribute.Attribute)
+		for valueIndex, value := range attribute.Values {
+			f.SetCellValue(attributesSheetName, spreadsheetCell(valueIndex+1, rowIndex), value)
+		}
+		rowIndex++
+
+		for groupIndex, counts := range attribute.Counts {
+			f.SetCellValue(attributesSheetName, spreadsheetCell(0, rowIndex), names[groupIndex])
+			for valueIndex, count := range counts {
+				f.SetCellValue(attributesSheetName, spreadsheetCell(valueIndex+1, rowIndex), count)
+			}
+			rowIndex++
+		}
+
+		// Leave an empty row between attributes
+		rowIndex++
+	}
+
+	return nil
+}
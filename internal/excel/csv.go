@@ -0,0 +1,152 @@
+package excel
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kremec/edugroup/types"
+)
+
+const (
+	errOpeningCSVFile = "Error opening CSV file:"
+	errParsingCSVFile = "Error reading data from CSV file:"
+	errSavingCSVFile  = "Error saving CSV file:"
+)
+
+// CSVFiles names the CSV files that take the place of the workbook sheets.
+// Only Roster is required; it has the layout of the first sheet. The other
+// files have the layout of the matching constraint sheet, one group per
+// column, or of the fixed assignments, named groups, rankings or nominations
+// sheet.
+type CSVFiles struct {
+	Roster           string
+	Exclusions       string
+	Inclusions       string
+	SoftExclusions   string
+	SoftInclusions   string
+	FixedAssignments string
+	NamedGroups      string
+	Rankings         string
+	Nominations      string
+}
+
+// ReadCSVSubjectGroups loads the data for subject mode from CSV files. The
+// roster has a subject per column with the subject name in row 1.
+func ReadCSVSubjectGroups(files CSVFiles) (*types.GroupingData, error) {
+	sheets, err := readCSVFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return readSubjectGroups(sheets)
+}
+
+// ReadCSVNumGroups loads the data for number-of-groups and preference mode
+// from CSV files.
+// The roster has a student name per row in column A.
+func ReadCSVNumGroups(files CSVFiles) (*types.GroupingData, error) {
+	sheets, err := readCSVFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return readNumGroups(sheets)
+}
+
+func readCSVFiles(files CSVFiles) (tableSheets, error) {
+	paths := []string{files.Roster, files.Exclusions, files.Inclusions, files.SoftExclusions, files.SoftInclusions, files.FixedAssignments, files.NamedGroups, files.Rankings, files.Nominations}
+	sheets := tableSheets{sheets: make([][][]string, len(paths)), names: make([]string, len(paths))}
+	for index, path := range paths {
+		if path == "" {
+			continue
+		}
+
+		rows, err := readCSVFile(path)
+		if err != nil {
+			return tableSheets{}, err
+		}
+		sheets.sheets[index] = rows
+		sheets.names[index] = filepath.Base(path)
+	}
+
+	return sheets, nil
+}
+
+// readCSVFile reads every row of a CSV file. The delimiter is detected from
+// the first line, since spreadsheet programs in many locales export with
+// semicolons instead of commas.
+func readCSVFile(path string) ([][]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s\n%s", errOpeningCSVFile, err, errNotifyDeveloper)
+	}
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = csvDelimiter(content)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %s", errParsingCSVFile, path, err)
+	}
+
+	// Match excelize rows: no trailing empty cells and no trailing empty rows.
+	rows := make([][]string, len(records))
+	length := 0
+	for rowIndex, record := range records {
+		cells := len(record)
+		for cells > 0 && record[cells-1] == "" {
+			cells--
+		}
+		rows[rowIndex] = record[:cells]
+		if cells > 0 {
+			length = rowIndex + 1
+		}
+	}
+
+	return rows[:length], nil
+}
+
+func csvDelimiter(content []byte) rune {
+	firstLine, _, _ := bytes.Cut(content, []byte("\n"))
+
+	delimiter, count := ',', bytes.Count(firstLine, []byte(","))
+	for _, candidate := range []rune{';', '\t'} {
+		if n := bytes.Count(firstLine, []byte(string(candidate))); n > count {
+			delimiter, count = candidate, n
+		}
+	}
+
+	return delimiter
+}
+
+// ExportToCSV exports the groups to a CSV file with the layout of the groups
+// sheet written by ExportToExcel: one row per group, starting with its name
+// from names, or its number without names.
+func ExportToCSV(groups [][]string, names []string, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("%s %s\n%s", errSavingCSVFile, err, errNotifyDeveloper)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	labels := outputGroupNames(names, len(groups))
+	for i, group := range groups {
+		record := append([]string{labels[i]}, group...)
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("%s %s\n%s", errSavingCSVFile, err, errNotifyDeveloper)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("%s %s\n%s", errSavingCSVFile, err, errNotifyDeveloper)
+	}
+
+	return file.Close()
+}
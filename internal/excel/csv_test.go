@@ -0,0 +1,84 @@
+package excel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeCSV saves content to a file called name in dir and returns its path.
+func writeCSV(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadCSVNumGroups(t *testing.T) {
+	dir := t.TempDir()
+	files := CSVFiles{
+		// Spreadsheet programs in many locales write a byte order mark and
+		// separate values with semicolons.
+		Roster:      writeCSV(t, dir, "students.csv", "\ufeffAna;;\nBor\nCene\nDana\n"),
+		Exclusions:  writeCSV(t, dir, "exclusions.csv", "Ana,Cene\nBor,\n"),
+		NamedGroups: writeCSV(t, dir, "groups.csv", "Group\tCapacity\nLab\t2\nLibrary\n"),
+	}
+
+	data, err := ReadCSVNumGroups(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(data.Students, []string{"Ana", "Bor", "Cene", "Dana"}) {
+		t.Errorf("students %v", data.Students)
+	}
+	if len(data.Exclusions) != 2 || !slices.Equal(data.Exclusions[0], []string{"Ana", "Bor"}) || !slices.Equal(data.Exclusions[1], []string{"Cene"}) {
+		t.Errorf("exclusions %v", data.Exclusions)
+	}
+	if len(data.NamedGroups) != 2 || data.NamedGroups[0].Name != "Lab" || data.NamedGroups[0].Capacity != 2 || data.NamedGroups[1].Capacity != 0 {
+		t.Errorf("named groups %v", data.NamedGroups)
+	}
+}
+
+func TestReadCSVSubjectGroups(t *testing.T) {
+	dir := t.TempDir()
+	files := CSVFiles{Roster: writeCSV(t, dir, "subjects.csv", "Math,Art\nAna,Cene\nBor,Dana\n")}
+
+	data, err := ReadCSVSubjectGroups(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(data.Subjects, []string{"Math", "Art"}) || !slices.Equal(data.SubjectStudents["Art"], []string{"Cene", "Dana"}) {
+		t.Errorf("subjects %v with %v", data.Subjects, data.SubjectStudents)
+	}
+}
+
+func TestCSVIssuesNameFile(t *testing.T) {
+	dir := t.TempDir()
+	files := CSVFiles{
+		Roster:     writeCSV(t, dir, "students.csv", "Ana\nBor\nAna\n"),
+		Inclusions: writeCSV(t, dir, "together.csv", "Ana,Bor\nEva\n"),
+	}
+
+	_, err := ReadCSVNumGroups(files)
+	var inputErr *InputError
+	if !errors.As(err, &inputErr) {
+		t.Fatalf("got %v, want *InputError", err)
+	}
+	want := `student "Ana" is duplicated at students.csv!A1 and students.csv!A3`
+	if !slices.Contains(inputErr.Issues, want) {
+		t.Errorf("issues %q do not contain %q", inputErr.Issues, want)
+	}
+
+	files.Roster = writeCSV(t, dir, "students.csv", "Ana\nBor\n")
+	_, err = ReadCSVNumGroups(files)
+	if err == nil || !strings.Contains(err.Error(), `inclusion name "Eva" at together.csv!A2`) {
+		t.Errorf("got %v, want the unknown inclusion name with its file", err)
+	}
+}
@@ -1,11 +1,15 @@
 package excel
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/kremec/edugroup/internal/types"
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/types"
 
 	"github.com/xuri/excelize/v2"
 )
@@ -14,13 +18,23 @@ const (
 	errNotifyDeveloper     = "Please notify the developer of this error!"
 	errOpeningExcelFile    = "Error opening Excel file:"
 	errNoSheetsInExcelFile = "No sheets found in Excel file, make sure to create at least one sheet and fill it with student data!"
-	errNoDataInExcelFile   = "No data found in the first sheet, make sure to add subject headers and student data!"
+	errNoDataInExcelFile   = "No data found in the students sheet, make sure to add subject headers and student data!"
 	errParsingExcelFile    = "Error reading data from Excel file:"
-	errInvalidExcelInput   = "Invalid Excel input:"
+	errInvalidInput        = "Invalid input:"
 	errSavingExcelFile     = "Error saving Excel file:"
 	groupsSheetName        = "Groups"
+	summarySheetName       = "Summary"
+	attributesSheetName    = "Attributes"
+	repeatsSheetName       = "Repeat partners"
+	constraintsSheetName   = "Constraint check"
+	satisfactionSheetName  = "Satisfaction"
+	optionSheetPrefix      = "Option "
 )
 
+// ErrInvalidInput is wrapped by every error caused by invalid workbook, CSV or
+// JSON content, as opposed to errors opening, reading or saving the file itself.
+var ErrInvalidInput = errors.New(errInvalidInput)
+
 type cellValueRef struct {
 	value string
 	cell  string
@@ -39,66 +53,149 @@ func (v *validationErrors) err() error {
 		return nil
 	}
 
-	return fmt.Errorf("%s\n- %s", errInvalidExcelInput, strings.Join(v.issues, "\n- "))
+	return &InputError{Issues: v.issues}
+}
+
+// InputError lists everything wrong with the content of an input file, with
+// the cells or JSON paths involved, so that all of it can be fixed at once.
+// It wraps ErrInvalidInput.
+type InputError struct {
+	Issues []string
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("%s\n- %s", errInvalidInput, strings.Join(e.Issues, "\n- "))
 }
 
-// ReadExcelSubjectGroups loads the data from the specified Excel file.
-func ReadExcelSubjectGroups(filename string) (*types.GroupingData, error) {
+func (e *InputError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// ReadExcelSubjectGroups loads the data from the specified Excel file, finding
+// its sheets and data by layout.
+func ReadExcelSubjectGroups(filename string, layout InputLayout) (*types.GroupingData, error) {
 	f, err := excelize.OpenFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
 	}
 	defer f.Close()
 
-	subjectStudents, err := getSubjectsStudents(f)
+	return readWorkbook(f, layout, readSubjectGroups)
+}
+
+// DecodeExcelSubjectGroups reads a workbook like ReadExcelSubjectGroups, from
+// r instead of a file.
+func DecodeExcelSubjectGroups(r io.Reader, layout InputLayout) (*types.GroupingData, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		issues := &validationErrors{}
+		issues.add("not an Excel workbook: %s", err)
+		return nil, issues.err()
+	}
+	defer f.Close()
+
+	return readWorkbook(f, layout, readSubjectGroups)
+}
+
+// readWorkbook finds the sheets and data of f by layout and reads them with
+// read.
+func readWorkbook(f *excelize.File, layout InputLayout, read func(sheetSource) (*types.GroupingData, error)) (*types.GroupingData, error) {
+	sheets, err := newWorkbookSheets(f, layout.Sheets)
+	if err != nil {
+		return nil, err
+	}
+
+	return read(newLayoutSheets(sheets, layout))
+}
+
+func readSubjectGroups(f sheetSource) (*types.GroupingData, error) {
+	subjects, subjectStudents, attributes, err := getSubjectsStudents(f)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions, exclusionCells, err := getExclusions(f, flattenSubjectStudents(subjects, subjectStudents))
+	if err != nil {
+		return nil, err
+	}
+
+	inclusions, inclusionCells, err := getInclusions(f, flattenSubjectStudents(subjects, subjectStudents))
 	if err != nil {
 		return nil, err
 	}
 
-	exclusions, err := getExclusions(f, flattenSubjectStudents(subjectStudents))
+	softExclusions, err := getSoftExclusions(f, flattenSubjectStudents(subjects, subjectStudents))
 	if err != nil {
 		return nil, err
 	}
 
-	inclusions, err := getInclusions(f, flattenSubjectStudents(subjectStudents))
+	softInclusions, err := getSoftInclusions(f, flattenSubjectStudents(subjects, subjectStudents))
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, assignmentCells, err := getAssignments(f, flattenSubjectStudents(subjects, subjectStudents), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	nominations, err := getNominations(f, flattenSubjectStudents(subjects, subjectStudents))
 	if err != nil {
 		return nil, err
 	}
 
 	data := &types.GroupingData{
-		SubjectStudents: subjectStudents,
-		Exclusions:      exclusions,
-		Inclusions:      inclusions,
+		Subjects:          subjects,
+		SubjectStudents:   subjectStudents,
+		Exclusions:        exclusions,
+		Inclusions:        inclusions,
+		ExclusionCells:    exclusionCells,
+		InclusionCells:    inclusionCells,
+		SoftExclusions:    softExclusions,
+		SoftInclusions:    softInclusions,
+		Nominations:       nominations,
+		Assignments:       assignments,
+		AssignmentCells:   assignmentCells,
+		Attributes:        attributes.names,
+		StudentAttributes: attributes.values,
 	}
 
 	return data, nil
 }
 
-// Read subjects and their students from the 1st sheet of Excel file
-func getSubjectsStudents(f *excelize.File) (map[string][]string, error) {
+// Read subjects and their students from the students sheet of Excel file.
+// Subjects are returned in column order. A column with a header in square
+// brackets holds an attribute of the students in the nearest subject column
+// to its left.
+func getSubjectsStudents(f sheetSource) ([]string, map[string][]string, *studentAttributes, error) {
+	subjectOrder := make([]string, 0)
 	subjectStudents := make(map[string][]string)
+	issues := &validationErrors{}
 
-	// If there is no 1st sheet, throw an error
-	if f.GetSheetName(0) == "" {
-		return nil, fmt.Errorf("%s", errNoSheetsInExcelFile)
+	// If there is no students sheet, throw an error
+	if !f.hasSheet(rosterSheet) {
+		issues.add("%s", errNoSheetsInExcelFile)
+		return nil, nil, nil, issues.err()
 	}
 
-	rows, err := f.GetRows(f.GetSheetName(0))
+	rows, err := f.getRows(rosterSheet)
 	if err != nil {
-		return nil, fmt.Errorf("%s %s\n%s", errParsingExcelFile, err, errNotifyDeveloper)
+		return nil, nil, nil, sheetError(err)
 	}
 	if len(rows) == 0 {
-		return nil, fmt.Errorf("%s", errNoDataInExcelFile)
+		issues.add("%s", errNoDataInExcelFile)
+		return nil, nil, nil, issues.err()
 	}
 
-	issues := &validationErrors{}
 	if countNonEmptyCells(rows[0]) == 0 {
-		issues.add("row 1 of the first sheet is empty; subject headers must start in row 1")
-		return nil, issues.err()
+		headerRow := rowOf(f.cellName(rosterSheet, 0, 0))
+		issues.add("%s of the students sheet is empty; subject headers must start in %s", headerRow, headerRow)
+		return nil, nil, nil, issues.err()
 	}
 
 	if countNonEmptyCells(rows[0]) == 1 && len(rows) > 1 && countNonEmptyCells(rows[1]) > 1 {
-		issues.add("row 1 of the first sheet looks like a title row; subject headers must start in row 1")
+		headerRow := rowOf(f.cellName(rosterSheet, 0, 0))
+		issues.add("%s of the students sheet looks like a title row; subject headers must start in %s, or set where they start in an input layout file", headerRow, headerRow)
 	}
 
 	subjects := rows[0]
@@ -106,10 +203,14 @@ func getSubjectsStudents(f *excelize.File) (map[string][]string, error) {
 	seenSubjects := make(map[string]cellValueRef)
 	seenStudents := make(map[string]cellValueRef)
 	seenStudentsNormalized := make(map[string]cellValueRef)
+	attributes := newStudentAttributes()
+	attributeColumns := make([]attributeColumn, 0)
+	studentAt := make(map[[2]int]string)
+	lastSubjectCol := -1
 
 	// Read students for each subject from the columns
 	for colIndex := 0; colIndex < maxCols; colIndex++ {
-		cell := spreadsheetCell(colIndex, 0)
+		cell := cellReference(f, rosterSheet, colIndex, 0)
 		rawSubject := ""
 		if colIndex < len(subjects) {
 			rawSubject = subjects[colIndex]
@@ -120,6 +221,19 @@ func getSubjectsStudents(f *excelize.File) (map[string][]string, error) {
 			issues.add("subject header at %s contains leading or trailing spaces", cell)
 		}
 
+		if name, ok := attributeHeader(subject); ok {
+			switch {
+			case name == "":
+				issues.add("attribute header at %s is empty", cell)
+			case lastSubjectCol < 0:
+				issues.add("attribute column %q at %s must be placed to the right of a subject column", name, cell)
+			default:
+				attributes.addName(name)
+				attributeColumns = append(attributeColumns, attributeColumn{colIndex: colIndex, name: name, nameColIndex: lastSubjectCol})
+			}
+			continue
+		}
+
 		hasStudentsBelow := false
 		for rowIndex := 1; rowIndex < len(rows); rowIndex++ {
 			if colIndex < len(rows[rowIndex]) && trimmedValue(rows[rowIndex][colIndex]) != "" {
@@ -145,13 +259,14 @@ func getSubjectsStudents(f *excelize.File) (map[string][]string, error) {
 			continue
 		}
 		seenSubjects[subjectKey] = cellValueRef{value: subject, cell: cell}
+		lastSubjectCol = colIndex
 
 		for rowIndex := 1; rowIndex < len(rows); rowIndex++ {
 			if colIndex >= len(rows[rowIndex]) {
 				continue
 			}
 
-			studentCell := spreadsheetCell(colIndex, rowIndex)
+			studentCell := cellReference(f, rosterSheet, colIndex, rowIndex)
 			rawStudentName := rows[rowIndex][colIndex]
 			studentName := trimmedValue(rawStudentName)
 			if rawStudentName != "" && rawStudentName != studentName {
@@ -172,42 +287,68 @@ func getSubjectsStudents(f *excelize.File) (map[string][]string, error) {
 
 				seenStudents[studentName] = cellValueRef{value: studentName, cell: studentCell}
 				seenStudentsNormalized[studentKey] = cellValueRef{value: studentName, cell: studentCell}
+				studentAt[[2]int{colIndex, rowIndex}] = studentName
+				if len(subjectStudents[subject]) == 0 {
+					subjectOrder = append(subjectOrder, subject)
+				}
 				subjectStudents[subject] = append(subjectStudents[subject], studentName)
 			}
 		}
 	}
 
+	// Read attribute values of the students in the subject column to the left
+	for _, column := range attributeColumns {
+		for rowIndex := 1; rowIndex < len(rows); rowIndex++ {
+			student := studentAt[[2]int{column.nameColIndex, rowIndex}]
+			readAttributeValue(f, rows, column.colIndex, rowIndex, student, column.name, attributes, issues)
+		}
+	}
+
 	if len(subjectStudents) == 0 {
-		issues.add("no student names were found below the subject headers on the first sheet")
+		issues.add("no student names were found below the subject headers on the students sheet")
 	}
 
 	if err := issues.err(); err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
-	return subjectStudents, nil
+	return subjectOrder, subjectStudents, attributes, nil
+}
+
+// Read exclusions from the exclusions sheet of Excel file
+func getExclusions(f sheetSource, knownStudents []string) ([][]string, [][]string, error) {
+	return getConstraintGroups(f, exclusionsSheet, knownStudents, "exclusion")
+}
+
+// Read inclusions from the inclusions sheet of Excel file
+func getInclusions(f sheetSource, knownStudents []string) ([][]string, [][]string, error) {
+	return getConstraintGroups(f, inclusionsSheet, knownStudents, "inclusion")
 }
 
-// Read exclusions from the 2nd sheet of Excel file
-func getExclusions(f *excelize.File, knownStudents []string) ([][]string, error) {
-	return getConstraintGroups(f, 1, knownStudents, "exclusion")
+// Read soft exclusions ("prefer apart") from the soft exclusions sheet of Excel file
+func getSoftExclusions(f sheetSource, knownStudents []string) ([][]string, error) {
+	groups, _, err := getConstraintGroups(f, softExclusionsSheet, knownStudents, "soft exclusion")
+	return groups, err
 }
 
-// Read inclusions from the 3rd sheet of Excel file
-func getInclusions(f *excelize.File, knownStudents []string) ([][]string, error) {
-	return getConstraintGroups(f, 2, knownStudents, "inclusion")
+// Read soft inclusions ("prefer together") from the soft inclusions sheet of Excel file
+func getSoftInclusions(f sheetSource, knownStudents []string) ([][]string, error) {
+	groups, _, err := getConstraintGroups(f, softInclusionsSheet, knownStudents, "soft inclusion")
+	return groups, err
 }
 
-func getConstraintGroups(f *excelize.File, sheetIndex int, knownStudents []string, constraintName string) ([][]string, error) {
+// getConstraintGroups reads one group per column of the sheet, together with
+// a reference to the cell of every name.
+func getConstraintGroups(f sheetSource, sheetIndex int, knownStudents []string, constraintName string) ([][]string, [][]string, error) {
 
 	// If the sheet is missing, assume no constraints of that type.
-	if f.GetSheetName(sheetIndex) == "" {
-		return make([][]string, 0), nil
+	if !f.hasSheet(sheetIndex) {
+		return make([][]string, 0), make([][]string, 0), nil
 	}
 
-	columns, err := f.GetCols(f.GetSheetName(sheetIndex))
+	columns, err := f.getCols(sheetIndex)
 	if err != nil {
-		return nil, fmt.Errorf("%s %s\n%s", errParsingExcelFile, err, errNotifyDeveloper)
+		return nil, nil, sheetError(err)
 	}
 
 	issues := &validationErrors{}
@@ -218,12 +359,14 @@ func getConstraintGroups(f *excelize.File, sheetIndex int, knownStudents []strin
 
 	seenAcrossGroups := make(map[string]cellValueRef)
 	exclusions := make([][]string, 0, len(columns))
+	references := make([][]string, 0, len(columns))
 	for colIndex, column := range columns {
 		exclusionGroup := make([]string, 0, len(column))
+		groupReferences := make([]string, 0, len(column))
 		seenInGroup := make(map[string]cellValueRef)
 
 		for rowIndex, rawName := range column {
-			cell := spreadsheetCell(colIndex, rowIndex)
+			cell := cellReference(f, sheetIndex, colIndex, rowIndex)
 			name := trimmedValue(rawName)
 
 			if rawName != "" && rawName != name {
@@ -236,12 +379,12 @@ func getConstraintGroups(f *excelize.File, sheetIndex int, knownStudents []strin
 
 			canonicalName, exists := knownStudentsNormalized[strings.ToLower(name)]
 			if !exists {
-				issues.add("%s name %q at %s does not match any student from the first sheet", constraintName, name, cell)
+				issues.add("%s name %q at %s does not match any student from the students sheet", constraintName, name, cell)
 				continue
 			}
 
 			if canonicalName != name {
-				issues.add("%s name %q at %s must match the first-sheet student name exactly: %q", constraintName, name, cell, canonicalName)
+				issues.add("%s name %q at %s must match the students-sheet name exactly: %q", constraintName, name, cell, canonicalName)
 				continue
 			}
 
@@ -258,79 +401,164 @@ func getConstraintGroups(f *excelize.File, sheetIndex int, knownStudents []strin
 			seenInGroup[name] = cellValueRef{value: name, cell: cell}
 			seenAcrossGroups[name] = cellValueRef{value: name, cell: cell}
 			exclusionGroup = append(exclusionGroup, name)
+			groupReferences = append(groupReferences, cellReference(f, sheetIndex, colIndex, rowIndex))
 		}
 
 		if len(exclusionGroup) > 0 {
 			exclusions = append(exclusions, exclusionGroup)
+			references = append(references, groupReferences)
 		}
 	}
 
 	if err := issues.err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return exclusions, nil
+	return exclusions, references, nil
 }
 
-func ReadExcelNumGroups(filename string) (*types.GroupingData, error) {
+// ReadExcelNumGroups loads the data for number-of-groups and preference mode
+// from the specified Excel file, finding its sheets and data by layout.
+func ReadExcelNumGroups(filename string, layout InputLayout) (*types.GroupingData, error) {
 	f, err := excelize.OpenFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
 	}
 	defer f.Close()
 
-	students, err := getStudents(f)
+	return readWorkbook(f, layout, readNumGroups)
+}
+
+// DecodeExcelNumGroups reads a workbook like ReadExcelNumGroups, from r
+// instead of a file.
+func DecodeExcelNumGroups(r io.Reader, layout InputLayout) (*types.GroupingData, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		issues := &validationErrors{}
+		issues.add("not an Excel workbook: %s", err)
+		return nil, issues.err()
+	}
+	defer f.Close()
+
+	return readWorkbook(f, layout, readNumGroups)
+}
+
+func readNumGroups(f sheetSource) (*types.GroupingData, error) {
+	students, attributes, err := getStudents(f)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions, exclusionCells, err := getExclusions(f, students)
+	if err != nil {
+		return nil, err
+	}
+
+	inclusions, inclusionCells, err := getInclusions(f, students)
+	if err != nil {
+		return nil, err
+	}
+
+	softExclusions, err := getSoftExclusions(f, students)
 	if err != nil {
 		return nil, err
 	}
 
-	exclusions, err := getExclusions(f, students)
+	softInclusions, err := getSoftInclusions(f, students)
 	if err != nil {
 		return nil, err
 	}
 
-	inclusions, err := getInclusions(f, students)
+	namedGroups, err := getNamedGroups(f)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, assignmentCells, err := getAssignments(f, students, namedGroupNames(namedGroups))
+	if err != nil {
+		return nil, err
+	}
+
+	rankings, err := getRankings(f, students, namedGroupNames(namedGroups))
+	if err != nil {
+		return nil, err
+	}
+
+	nominations, err := getNominations(f, students)
 	if err != nil {
 		return nil, err
 	}
 
 	data := &types.GroupingData{
-		Students:   students,
-		Exclusions: exclusions,
-		Inclusions: inclusions,
+		Students:          students,
+		Exclusions:        exclusions,
+		Inclusions:        inclusions,
+		ExclusionCells:    exclusionCells,
+		InclusionCells:    inclusionCells,
+		SoftExclusions:    softExclusions,
+		SoftInclusions:    softInclusions,
+		Nominations:       nominations,
+		Assignments:       assignments,
+		AssignmentCells:   assignmentCells,
+		NamedGroups:       namedGroups,
+		Rankings:          rankings,
+		Attributes:        attributes.names,
+		StudentAttributes: attributes.values,
 	}
 
 	return data, nil
 }
 
-func getStudents(f *excelize.File) ([]string, error) {
+// Read student names from column A of the students sheet of Excel file. If row 1
+// has headers in square brackets next to column A, it is a header row and
+// those columns hold student attributes.
+func getStudents(f sheetSource) ([]string, *studentAttributes, error) {
+	issues := &validationErrors{}
 
-	// If there is no 1st sheet, throw an error
-	if f.GetSheetName(0) == "" {
-		return nil, fmt.Errorf("%s", errNoSheetsInExcelFile)
+	// If there is no students sheet, throw an error
+	if !f.hasSheet(rosterSheet) {
+		issues.add("%s", errNoSheetsInExcelFile)
+		return nil, nil, issues.err()
 	}
 
-	rows, err := f.GetRows(f.GetSheetName(0))
+	rows, err := f.getRows(rosterSheet)
 	if err != nil {
-		return nil, fmt.Errorf("%s %s\n%s", errParsingExcelFile, err, errNotifyDeveloper)
+		return nil, nil, sheetError(err)
 	}
 	if len(rows) == 0 {
-		return nil, fmt.Errorf("%s", errNoDataInExcelFile)
+		issues.add("%s", errNoDataInExcelFile)
+		return nil, nil, issues.err()
 	}
 
-	issues := &validationErrors{}
 	students := make([]string, 0, len(rows))
 	seenStudents := make(map[string]cellValueRef)
 	seenStudentsNormalized := make(map[string]cellValueRef)
 
-	for rowIndex, row := range rows {
-		if rowIndex == 0 && (len(row) == 0 || trimmedValue(row[0]) == "") {
-			issues.add("cell A1 must contain the first student name in number-of-groups mode")
+	// Attribute columns turn row 1 into a header row
+	attributes := newStudentAttributes()
+	attributeColumns := getAttributeColumns(f, rows[0], issues)
+	isAttributeColumn := make(map[int]bool)
+	firstRow := 0
+	if len(attributeColumns) > 0 {
+		firstRow = 1
+	}
+	for _, column := range attributeColumns {
+		attributes.addName(column.name)
+		isAttributeColumn[column.colIndex] = true
+	}
+
+	for rowIndex := firstRow; rowIndex < len(rows); rowIndex++ {
+		row := rows[rowIndex]
+		if rowIndex == firstRow && (len(row) == 0 || trimmedValue(row[0]) == "") {
+			issues.add("cell %s must contain the first student name in number-of-groups mode", cellReference(f, rosterSheet, 0, firstRow))
 		}
 
 		for colIndex := 1; colIndex < len(row); colIndex++ {
+			if isAttributeColumn[colIndex] {
+				continue
+			}
 			if trimmedValue(row[colIndex]) != "" {
-				issues.add("%s contains %q, but number-of-groups mode only reads student names from column A", spreadsheetCell(colIndex, rowIndex), row[colIndex])
+				issues.add("%s contains %q, but number-of-groups mode only reads student names from %s and attributes from columns with a [bracketed] header", cellReference(f, rosterSheet, colIndex, rowIndex), row[colIndex], columnOf(f.cellName(rosterSheet, 0, rowIndex)))
 			}
 		}
 
@@ -338,7 +566,7 @@ func getStudents(f *excelize.File) ([]string, error) {
 			continue
 		}
 
-		cell := spreadsheetCell(0, rowIndex)
+		cell := cellReference(f, rosterSheet, 0, rowIndex)
 		rawStudent := row[0]
 		student := trimmedValue(rawStudent)
 		if rawStudent != "" && rawStudent != student {
@@ -346,6 +574,9 @@ func getStudents(f *excelize.File) ([]string, error) {
 		}
 
 		if student == "" {
+			for _, column := range attributeColumns {
+				readAttributeValue(f, rows, column.colIndex, rowIndex, "", column.name, attributes, issues)
+			}
 			continue
 		}
 
@@ -363,50 +594,201 @@ func getStudents(f *excelize.File) ([]string, error) {
 		seenStudents[student] = cellValueRef{value: student, cell: cell}
 		seenStudentsNormalized[studentKey] = cellValueRef{value: student, cell: cell}
 		students = append(students, student)
+		for _, column := range attributeColumns {
+			readAttributeValue(f, rows, column.colIndex, rowIndex, student, column.name, attributes, issues)
+		}
 	}
 
 	if len(students) == 0 {
-		issues.add("no student names were found in column A of the first sheet")
+		issues.add("no student names were found in %s of the students sheet", columnOf(f.cellName(rosterSheet, 0, 0)))
 	}
 
 	if err := issues.err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return students, nil
+	return students, attributes, nil
 }
 
-// ExportToExcel exports the groups to an Excel file.
-func ExportToExcel(groups [][]string, filename string) error {
-	f := excelize.NewFile()
+// Option is one of the groupings written to an output workbook.
+type Option struct {
+	Groups [][]string
+	// Penalty scores the quality of the grouping; lower is better.
+	Penalty float64
+}
+
+// optionSheetName returns the name of the sheet of the option with the
+// given index when a workbook holds several options.
+func optionSheetName(optionIndex int) string {
+	return optionSheetPrefix + strconv.Itoa(optionIndex+1)
+}
+
+// Summary holds the run settings written next to the groups, so that the
+// grouping can be regenerated from the output file. With several options, the
+// results it describes are those of the first option.
+type Summary struct {
+	Mode      grouping.Mode
+	NumGroups int
+	Seed      int64
+	// GroupNames are the names of the groups when the input named them; the
+	// groups are numbered otherwise.
+	GroupNames []string
+	// InputFile is the file the students were read from and Created the time
+	// the grouping was made. Both are left out of the summary when empty.
+	InputFile string
+	Created   time.Time
+	// Data is the problem the groups were made for. When set, every
+	// exclusion and inclusion group is checked on a sheet of its own, and in
+	// subject mode the subject of every student is written next to their
+	// name.
+	Data *types.GroupingData
+	// ViolatedPreferences describes the soft preferences that were not met.
+	ViolatedPreferences []string
+	// UnmetWishes lists the students placed without any of the classmates
+	// they nominated; nil leaves them out of the summary when nobody
+	// nominated anyone.
+	UnmetWishes []grouping.UnmetWish
+	// Attributes is written to its own sheet when the roster had attributes.
+	Attributes []grouping.AttributeDistribution
+	// RepeatPartners is written to its own sheet when earlier groupings were
+	// given.
+	RepeatPartners []grouping.RepeatPartners
+	// Satisfaction is counted on the summary sheet and written to its own
+	// sheet in preference mode.
+	Satisfaction *grouping.Satisfaction
+}
+
+// ExportToExcel exports the options to an Excel file, arranged by layout on
+// the groups sheet, or on a sheet of their own each ("Option 1", "Option 2",
+// ...) when there are several.
+func ExportToExcel(options []Option, summary Summary, layout Layout, filename string) error {
+	f, err := newOutputWorkbook(options, summary, layout)
+	if err != nil {
+		return err
+	}
 	defer f.Close()
 
-	err := f.SetSheetName(f.GetSheetName(0), groupsSheetName)
+	err = f.SaveAs(filename)
+	if err != nil {
+		return fmt.Errorf("%s %s\n%s", errSavingExcelFile, err, errNotifyDeveloper)
+	}
+	return nil
+}
+
+// EncodeExcel writes the workbook of ExportToExcel to w.
+func EncodeExcel(w io.Writer, options []Option, summary Summary, layout Layout) error {
+	f, err := newOutputWorkbook(options, summary, layout)
 	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteTo(w); err != nil {
+		return fmt.Errorf("%s %s", errSavingExcelFile, err)
+	}
+	return nil
+}
+
+// newOutputWorkbook builds the output workbook with the groups sheets first.
+func newOutputWorkbook(options []Option, summary Summary, layout Layout) (*excelize.File, error) {
+	f := excelize.NewFile()
+	if err := writeOutputSheets(f, options, summary, layout); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func writeOutputSheets(f *excelize.File, options []Option, summary Summary, layout Layout) error {
+	if len(options) == 0 {
+		return fmt.Errorf("%s no groups to export\n%s", errSavingExcelFile, errNotifyDeveloper)
+	}
+
+	sheets := []string{groupsSheetName}
+	if len(options) > 1 {
+		sheets = make([]string, len(options))
+		for optionIndex := range options {
+			sheets[optionIndex] = optionSheetName(optionIndex)
+		}
+	}
+	err := f.SetSheetName(f.GetSheetName(0), sheets[0])
+	if err != nil {
+		return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
+	}
+	for _, sheet := range sheets[1:] {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
+		}
+	}
+
+	// The other sheets describe the first option.
+	groups := options[0].Groups
+	if err := writeSummarySheet(f, options, summary); err != nil {
 		return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
 	}
 
-	for i, group := range groups {
-		cell := fmt.Sprintf("%c%d", 'A', i+1)
-		f.SetCellValue(groupsSheetName, cell, "Group "+strconv.Itoa(i+1))
-		for j, student := range group {
-			cell := fmt.Sprintf("%c%d", 'A'+j+1, i+1)
-			f.SetCellValue(groupsSheetName, cell, student)
+	if summary.Data != nil {
+		if err := writeConstraintCheckSheet(f, groups, summary.GroupNames, summary.Data); err != nil {
+			return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
 		}
 	}
-	f.SetActiveSheet(0)
 
-	err = f.SaveAs(filename)
-	if err != nil {
-		return fmt.Errorf("%s %s\n%s", errSavingExcelFile, err, errNotifyDeveloper)
+	names := outputGroupNames(summary.GroupNames, len(groups))
+	if len(summary.Attributes) > 0 {
+		if err := writeAttributesSheet(f, summary.Attributes, names); err != nil {
+			return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
+		}
+	}
+
+	if len(summary.RepeatPartners) > 0 {
+		if err := writeRepeatPartnersSheet(f, summary.RepeatPartners); err != nil {
+			return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
+		}
 	}
+
+	if summary.Satisfaction != nil {
+		if err := writeSatisfactionSheet(f, groups, names, summary.Satisfaction); err != nil {
+			return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
+		}
+	}
+
+	studentSubject := make(map[string]string)
+	if summary.Data != nil && summary.Mode == grouping.BySubject {
+		for subject, students := range summary.Data.SubjectStudents {
+			for _, student := range students {
+				studentSubject[student] = subject
+			}
+		}
+	}
+	for optionIndex, option := range options {
+		sheet := sheets[optionIndex]
+		names := outputGroupNames(summary.GroupNames, len(option.Groups))
+		switch layout {
+		case LayoutColumns:
+			writeGroupColumns(f, sheet, option.Groups, names, studentSubject)
+		case LayoutTable:
+			// Table names must be unique within the workbook
+			table := groupsTable
+			if optionIndex > 0 {
+				table += strconv.Itoa(optionIndex + 1)
+			}
+			if err := writeGroupTable(f, sheet, table, option.Groups, names, studentSubject, summary.Data); err != nil {
+				return fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
+			}
+		default:
+			writeGroupRows(f, sheet, option.Groups, names, studentSubject)
+		}
+	}
+	f.SetActiveSheet(0)
+
 	return nil
 }
 
-func flattenSubjectStudents(subjectStudents map[string][]string) []string {
+func flattenSubjectStudents(subjects []string, subjectStudents map[string][]string) []string {
 	students := make([]string, 0)
-	for _, subjectGroup := range subjectStudents {
-		students = append(students, subjectGroup...)
+	for _, subject := range subjects {
+		students = append(students, subjectStudents[subject]...)
 	}
 
 	return students
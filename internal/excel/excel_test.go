@@ -0,0 +1,47 @@
+package excel
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// testSheet is a sheet of a workbook made by newWorkbook, with its cells row
+// by row from A1.
+type testSheet struct {
+	name string
+	rows [][]string
+}
+
+// newWorkbook saves a workbook with sheets, in order, to a temporary file and
+// returns its path.
+func newWorkbook(t *testing.T, sheets ...testSheet) string {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+	for i, sheet := range sheets {
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), sheet.name); err != nil {
+				t.Fatal(err)
+			}
+		} else if _, err := f.NewSheet(sheet.name); err != nil {
+			t.Fatal(err)
+		}
+		for rowIndex, row := range sheet.rows {
+			for colIndex, value := range row {
+				if value != "" {
+					f.SetCellValue(sheet.name, spreadsheetCell(colIndex, rowIndex), value)
+				}
+			}
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "input.xlsx")
+	if err := f.SaveAs(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	return filename
+}
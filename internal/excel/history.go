@@ -0,0 +1,237 @@
+package excel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kremec/edugroup/grouping"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const errReadingHistory = "Error reading earlier groupings:"
+
+// ReadHistory collects earlier groupings from path, which is either a single
+// output file or a folder of output files written by ExportToExcel (in any
+// layout), ExportToCSV or ExportToJSON. Files in the folder that do not hold groups,
+// such as input workbooks, are skipped.
+func ReadHistory(path string) (*grouping.History, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s", errReadingHistory, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s", errReadingHistory, err)
+		}
+
+		files = files[:0]
+		for _, entry := range entries {
+			// Skip lock files that Excel leaves next to open workbooks
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), "~$") {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+		sort.Strings(files)
+	}
+
+	history := grouping.NewHistory()
+	for _, file := range files {
+		groups, err := readEarlierGroups(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %s", errReadingHistory, file, err)
+		}
+		if len(groups) > 0 {
+			history.AddRound(groups)
+		}
+	}
+
+	return history, nil
+}
+
+// readEarlierGroups returns the groups of an output file, or no groups for
+// any other kind of file.
+func readEarlierGroups(filename string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx":
+		f, err := excelize.OpenFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		sheet := earlierGroupsSheet(f)
+		if sheet == "" {
+			return nil, nil
+		}
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			return nil, err
+		}
+		names, err := summaryGroupNames(f)
+		if err != nil {
+			return nil, err
+		}
+		return readGroupsSheet(rows, names), nil
+
+	case ".csv":
+		rows, err := readCSVFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return csvGroups(rows), nil
+
+	case ".json":
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		// Problems and other JSON files have no groups, and neither do
+		// results with alternatives nobody picked from yet
+		var result jsonResult
+		if json.Unmarshal(content, &result) != nil || len(result.Alternatives) > 0 {
+			return nil, nil
+		}
+		return result.Groups, nil
+	}
+
+	return nil, nil
+}
+
+// earlierGroupsSheet returns the name of the sheet holding the groups of an
+// output workbook: the groups sheet, or the only option sheet left once the
+// options that were not picked have been deleted. It returns "" for any
+// other workbook.
+func earlierGroupsSheet(f *excelize.File) string {
+	if index, _ := f.GetSheetIndex(groupsSheetName); index >= 0 {
+		return groupsSheetName
+	}
+
+	sheet := ""
+	for _, name := range f.GetSheetList() {
+		if !strings.HasPrefix(name, optionSheetPrefix) {
+			continue
+		}
+		if sheet != "" {
+			return ""
+		}
+		sheet = name
+	}
+
+	return sheet
+}
+
+// summaryGroupNames returns the group names listed above the group sizes on
+// the summary sheet of an output workbook, or none without that sheet.
+func summaryGroupNames(f *excelize.File) ([]string, error) {
+	if index, _ := f.GetSheetIndex(summarySheetName); index < 0 {
+		return nil, nil
+	}
+	rows, err := f.GetRows(summarySheetName)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	inSizes := false
+	for _, row := range rows {
+		name := trimmedValue(cellValue(row, 0))
+		switch {
+		case inSizes && name == "":
+			return names, nil
+		case inSizes:
+			names = append(names, name)
+		case name == groupHeader && trimmedValue(cellValue(row, 1)) == sizeHeader:
+			inSizes = true
+		}
+	}
+
+	return names, nil
+}
+
+// csvGroups reads the groups of a CSV file written by ExportToCSV, where
+// column A of every row holds the group label, numbered or named. Files that
+// cannot be a grouping, because a label or a student appears twice or no
+// group has any students, such as input files, have no groups.
+func csvGroups(rows [][]string) [][]string {
+	labels := make([]string, 0, len(rows))
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		label := trimmedValue(row[0])
+		if label == "" || seen[label] {
+			return nil
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+
+	groups := groupRows(rows, labels)
+	students := 0
+	for _, group := range groups {
+		for _, student := range group {
+			if seen[student] {
+				return nil
+			}
+			seen[student] = true
+			students++
+		}
+	}
+	if students == 0 {
+		return nil
+	}
+
+	return groups
+}
+
+// groupRows reads the rows of a groups sheet or CSV file that start with
+// the group name, numbered or one of names, followed by the students of
+// that group.
+func groupRows(rows [][]string, names []string) [][]string {
+	groups := make([][]string, 0, len(rows))
+	for _, row := range rows {
+		if len(row) == 0 || !isGroupName(trimmedValue(row[0]), names) {
+			continue
+		}
+
+		group := make([]string, 0, len(row)-1)
+		for _, cell := range row[1:] {
+			if student := trimmedValue(cell); student != "" {
+				group = append(group, student)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// writeRepeatPartnersSheet lists every student grouped with someone again,
+// with the number of repeat partners and their names.
+func writeRepeatPartnersSheet(f *excelize.File, repeats []grouping.RepeatPartners) error {
+	if _, err := f.NewSheet(repeatsSheetName); err != nil {
+		return err
+	}
+
+	f.SetCellValue(repeatsSheetName, "A1", "Student")
+	f.SetCellValue(repeatsSheetName, "B1", "Repeat partners")
+	f.SetCellValue(repeatsSheetName, "C1", "Partners")
+	for i, repeat := range repeats {
+		f.SetCellValue(repeatsSheetName, spreadsheetCell(0, i+1), repeat.Student)
+		f.SetCellValue(repeatsSheetName, spreadsheetCell(1, i+1), len(repeat.Partners))
+		f.SetCellValue(repeatsSheetName, spreadsheetCell(2, i+1), strings.Join(repeat.Partners, ", "))
+	}
+
+	return nil
+}
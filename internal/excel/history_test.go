@@ -0,0 +1,76 @@
+package excel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kremec/edugroup/grouping"
+)
+
+func TestReadHistoryFolder(t *testing.T) {
+	dir := t.TempDir()
+	week1 := [][]string{{"Ana", "Bor"}, {"Cene", "Dana"}}
+	if err := ExportToExcel([]Option{{Groups: week1}}, Summary{Mode: grouping.ByCount, NumGroups: 2}, LayoutRows, filepath.Join(dir, "week1.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportToCSV([][]string{{"Ana", "Cene"}, {"Bor", "Dana"}}, nil, filepath.Join(dir, "week2.csv")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExportToJSON([]*grouping.Result{{Groups: [][]string{{"Ana", "Dana"}, {"Bor", "Cene"}}}}, filepath.Join(dir, "week3.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files without groups are skipped: the input workbook, a JSON problem,
+	// a result with options nobody picked from, notes and the lock file Excel
+	// leaves next to an open workbook.
+	input := newWorkbook(t, testSheet{name: "Students", rows: [][]string{{"Ana"}, {"Bor"}, {"Cene"}, {"Dana"}}})
+	content, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	others := map[string]string{
+		"students.xlsx": string(content),
+		"problem.json":  `{"students": ["Ana", "Bor", "Cene", "Dana"], "options": {"numGroups": 2}}`,
+		"options.json":  `{"groups": [["Ana", "Bor"], ["Cene", "Dana"]], "alternatives": [{"groups": [["Ana", "Cene"], ["Bor", "Dana"]]}]}`,
+		"notes.txt":     "Ana and Bor worked well together",
+		"~$week1.xlsx":  "locked",
+	}
+	for name, content := range others {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history, err := ReadHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rounds := history.Rounds(); rounds != 3 {
+		t.Errorf("%d groupings read, want 3", rounds)
+	}
+	for _, pair := range [][2]string{{"Ana", "Bor"}, {"Ana", "Cene"}, {"Ana", "Dana"}, {"Bor", "Cene"}, {"Bor", "Dana"}, {"Cene", "Dana"}} {
+		if count := history.Count(pair[0], pair[1]); count != 1 {
+			t.Errorf("%s and %s were together %d times, want 1", pair[0], pair[1], count)
+		}
+	}
+}
+
+func TestReadHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.csv")
+	if err := ExportToCSV([][]string{{"Ana", "Bor"}, {"Cene"}}, []string{"Lab", "Library"}, path); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := ReadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.Rounds() != 1 || history.Count("Ana", "Bor") != 1 || history.Count("Bor", "Cene") != 0 {
+		t.Errorf("%d groupings read with Ana and Bor together %d times", history.Rounds(), history.Count("Ana", "Bor"))
+	}
+
+	if _, err := ReadHistory(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("a missing history was read without an error")
+	}
+}
@@ -0,0 +1,296 @@
+package excel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const errReadingInputLayout = "Error reading input layout file:"
+
+// Ways of splitting student names over two columns.
+const (
+	FirstNameLastName = "first-last"
+	LastNameFirstName = "last-first"
+)
+
+// InputLayout describes where the data is in an Excel workbook that does not
+// follow the default layout, e.g. one with a title row above the subject
+// headers or with first and last names in separate columns.
+type InputLayout struct {
+	// Sheets lists the names the input sheets are recognized by.
+	Sheets SheetNames `json:"sheets"`
+	// Students is the layout of the students sheet.
+	Students SheetLayout `json:"students"`
+	// Constraints is the layout of the exclusions, inclusions, prefer apart,
+	// prefer together and fixed assignments sheets, and where the data
+	// starts on the named groups sheet, whose group names are never split.
+	Constraints SheetLayout `json:"constraints"`
+}
+
+// SheetLayout describes where the data starts on a sheet and how names are
+// written.
+type SheetLayout struct {
+	// Start is the top left cell of the data, "A1" when empty. On the
+	// students sheet that is the first subject header in subject mode, and
+	// the first student name (or the header row above it) in
+	// number-of-groups mode. Rows above and columns left of it are ignored.
+	Start string `json:"start"`
+	// SplitNames tells that every name takes two columns, FirstNameLastName
+	// or LastNameFirstName. Names are read as "First Last". Attribute
+	// columns still take one column each. Empty for names in one column.
+	SplitNames string `json:"splitNames"`
+}
+
+// DefaultInputLayout returns the layout of workbooks with all data starting
+// in cell A1 and the sheets recognized by DefaultSheetNames.
+func DefaultInputLayout() InputLayout {
+	return InputLayout{Sheets: DefaultSheetNames()}
+}
+
+// ReadInputLayout reads an input layout from a JSON or YAML file. Sheet names
+// in the file are recognized in addition to DefaultSheetNames.
+func ReadInputLayout(filename string) (InputLayout, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return InputLayout{}, fmt.Errorf("%s %s", errReadingInputLayout, err)
+	}
+
+	// YAML is converted to JSON, so that both are decoded by the same rules.
+	issues := &validationErrors{}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		var document any
+		if err := yaml.Unmarshal(content, &document); err != nil {
+			issues.add("%s: %s", filename, err)
+			return InputLayout{}, issues.err()
+		}
+		if content, err = json.Marshal(document); err != nil {
+			issues.add("%s: %s", filename, err)
+			return InputLayout{}, issues.err()
+		}
+	case ".json":
+	default:
+		return InputLayout{}, fmt.Errorf("%s %s: expected a .json, .yaml or .yml file", errReadingInputLayout, filename)
+	}
+
+	var fileLayout InputLayout
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&fileLayout); err != nil {
+		issues.add("%s: %s", filename, err)
+		return InputLayout{}, issues.err()
+	}
+
+	fileLayout.Students.validate("students", issues)
+	fileLayout.Constraints.validate("constraints", issues)
+	if err := issues.err(); err != nil {
+		return InputLayout{}, err
+	}
+
+	layout := DefaultInputLayout()
+	layout.Sheets.Extend(fileLayout.Sheets)
+	layout.Students = fileLayout.Students
+	layout.Constraints = fileLayout.Constraints
+
+	return layout, nil
+}
+
+func (l SheetLayout) validate(name string, issues *validationErrors) {
+	if l.Start != "" {
+		if _, _, err := excelize.CellNameToCoordinates(l.Start); err != nil {
+			issues.add("%s.start %q is not a cell name like \"B3\"", name, l.Start)
+		}
+	}
+	if l.SplitNames != "" && l.SplitNames != FirstNameLastName && l.SplitNames != LastNameFirstName {
+		issues.add("%s.splitNames %q must be %q or %q", name, l.SplitNames, FirstNameLastName, LastNameFirstName)
+	}
+}
+
+// start returns the zero-based column and row of Start.
+func (l SheetLayout) start() (int, int) {
+	col, row, err := excelize.CellNameToCoordinates(l.Start)
+	if err != nil {
+		return 0, 0
+	}
+
+	return col - 1, row - 1
+}
+
+// layoutSheets shows the sheets of a source as if their data started in
+// cell A1 with every name in a single column, while validation errors still
+// refer to the cells of the source.
+type layoutSheets struct {
+	source  sheetSource
+	layouts [sheetRoleCount]SheetLayout
+	// rows and colMaps cache every sheet as seen through its layout, and the
+	// source column of every column of it.
+	rows    [sheetRoleCount][][]string
+	colMaps [sheetRoleCount][]int
+}
+
+// newLayoutSheets applies layout to source, or returns source unchanged for
+// the default layout.
+func newLayoutSheets(source sheetSource, layout InputLayout) sheetSource {
+	if layout.Students == (SheetLayout{}) && layout.Constraints == (SheetLayout{}) {
+		return source
+	}
+
+	sheets := &layoutSheets{source: source}
+	for index := range sheets.layouts {
+		sheets.layouts[index] = layout.Constraints
+	}
+	sheets.layouts[rosterSheet] = layout.Students
+	sheets.layouts[namedGroupsSheet] = SheetLayout{Start: layout.Constraints.Start}
+	sheets.layouts[rankingsSheet] = SheetLayout{Start: layout.Constraints.Start}
+
+	return sheets
+}
+
+func (l *layoutSheets) hasSheet(index int) bool {
+	return l.source.hasSheet(index)
+}
+
+func (l *layoutSheets) getRows(index int) ([][]string, error) {
+	if l.rows[index] != nil {
+		return l.rows[index], nil
+	}
+
+	rows, err := l.source.getRows(index)
+	if err != nil {
+		return nil, err
+	}
+
+	startCol, startRow := l.layouts[index].start()
+	rows = rows[min(startRow, len(rows)):]
+
+	// Pair up the name columns; attribute columns are marked in the first
+	// row and never split.
+	colMap := make([]int, 0)
+	paired := make([]bool, 0)
+	for colIndex, maxCols := startCol, maxColumnCount(rows); colIndex < maxCols; {
+		pair := l.layouts[index].SplitNames != ""
+		if pair && len(rows) > 0 && colIndex < len(rows[0]) {
+			_, isAttribute := attributeHeader(rows[0][colIndex])
+			pair = !isAttribute
+		}
+
+		colMap = append(colMap, colIndex)
+		paired = append(paired, pair)
+		colIndex++
+		if pair {
+			colIndex++
+		}
+	}
+
+	// Split names are checked for spaces before they are joined, as the
+	// joined name no longer shows them.
+	issues := &validationErrors{}
+	view := make([][]string, len(rows))
+	for rowIndex, row := range rows {
+		// Trailing empty cells are left out, like excelize does.
+		view[rowIndex] = make([]string, 0, len(colMap))
+		length := 0
+		for i, colIndex := range colMap {
+			value := cellValue(row, colIndex)
+			if paired[i] {
+				for _, nameCol := range []int{colIndex, colIndex + 1} {
+					if rawName := cellValue(row, nameCol); rawName != trimmedValue(rawName) {
+						issues.add("name at %s contains leading or trailing spaces", cellReference(l.source, index, nameCol, rowIndex+startRow))
+					}
+				}
+				value = joinNames(row, colIndex, l.layouts[index].SplitNames)
+			}
+			view[rowIndex] = append(view[rowIndex], value)
+			if value != "" {
+				length = i + 1
+			}
+		}
+		view[rowIndex] = view[rowIndex][:length]
+	}
+	if err := issues.err(); err != nil {
+		return nil, err
+	}
+
+	l.rows[index] = view
+	l.colMaps[index] = colMap
+	return view, nil
+}
+
+func cellValue(row []string, colIndex int) string {
+	if colIndex < len(row) {
+		return row[colIndex]
+	}
+
+	return ""
+}
+
+// joinNames returns the name split over the columns colIndex and colIndex+1
+// as "First Last". getRows has already reported spaces around either half.
+func joinNames(row []string, colIndex int, splitNames string) string {
+	first, last := trimmedValue(cellValue(row, colIndex)), trimmedValue(cellValue(row, colIndex+1))
+	if splitNames == LastNameFirstName {
+		first, last = last, first
+	}
+
+	return strings.TrimSpace(first + " " + last)
+}
+
+func (l *layoutSheets) getCols(index int) ([][]string, error) {
+	rows, err := l.getRows(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return tableSheets{sheets: [][][]string{rows}}.getCols(0)
+}
+
+func (l *layoutSheets) cellName(sheetIndex int, colIndex int, rowIndex int) string {
+	startCol, startRow := l.layouts[sheetIndex].start()
+	sourceCol := colIndex + startCol
+	if colIndex < len(l.colMaps[sheetIndex]) {
+		sourceCol = l.colMaps[sheetIndex][colIndex]
+	}
+
+	return l.source.cellName(sheetIndex, sourceCol, rowIndex+startRow)
+}
+
+func (l *layoutSheets) sheetName(index int) string {
+	return l.source.sheetName(index)
+}
+
+// rowOf and columnOf describe the row or column of a cell in messages, e.g.
+// "row 3" for "B3" or "Exclusions!B3", or return the cell name of sources
+// without spreadsheet cells.
+func rowOf(cell string) string {
+	if _, row, err := excelize.SplitCellName(unqualifiedCell(cell)); err == nil {
+		return "row " + strconv.Itoa(row)
+	}
+
+	return cell
+}
+
+func columnOf(cell string) string {
+	if col, _, err := excelize.SplitCellName(unqualifiedCell(cell)); err == nil {
+		return "column " + col
+	}
+
+	return cell
+}
+
+// unqualifiedCell leaves out the sheet name of a cell reference made by
+// cellReference.
+func unqualifiedCell(cell string) string {
+	if index := strings.LastIndex(cell, "!"); index >= 0 {
+		return cell[index+1:]
+	}
+
+	return cell
+}
@@ -0,0 +1,127 @@
+package excel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadInputLayout(t *testing.T) {
+	files := map[string]string{
+		"layout.yaml": `
+students:
+  start: B3
+  splitNames: first-last
+constraints:
+  start: A2
+  splitNames: last-first
+sheets:
+  students: [Class 3A]
+`,
+		"layout.json": `{
+			"students": {"start": "B3", "splitNames": "first-last"},
+			"constraints": {"start": "A2", "splitNames": "last-first"},
+			"sheets": {"students": ["Class 3A"]}
+		}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			layout, err := ReadInputLayout(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if layout.Students != (SheetLayout{Start: "B3", SplitNames: FirstNameLastName}) {
+				t.Errorf("students layout %+v", layout.Students)
+			}
+			if layout.Constraints != (SheetLayout{Start: "A2", SplitNames: LastNameFirstName}) {
+				t.Errorf("constraints layout %+v", layout.Constraints)
+			}
+			// The names of the file are added to the default names.
+			if !slices.Contains(layout.Sheets.Students, "Class 3A") || !slices.Contains(layout.Sheets.Students, "Students") {
+				t.Errorf("students sheet names %v", layout.Sheets.Students)
+			}
+		})
+	}
+}
+
+func TestReadInputLayoutIssues(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		issue   string
+	}{
+		{"layout.yaml", "students:\n  start: third row\n", `students.start "third row" is not a cell name`},
+		{"layout.json", `{"constraints": {"splitNames": "first"}}`, `constraints.splitNames "first" must be`},
+		{"layout.json", `{"students": {"begin": "B3"}}`, "unknown field"},
+		{"layout.yaml", "students: [B3", "layout.yaml"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.issue, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.name)
+			if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := ReadInputLayout(path)
+			if !errors.Is(err, ErrInvalidInput) || !strings.Contains(err.Error(), test.issue) {
+				t.Errorf("got %v, want invalid input mentioning %q", err, test.issue)
+			}
+		})
+	}
+
+	if _, err := ReadInputLayout(filepath.Join(t.TempDir(), "layout.txt")); err == nil || errors.Is(err, ErrInvalidInput) {
+		t.Errorf("got %v for a missing file of an unknown kind", err)
+	}
+}
+
+func TestInputLayoutSplitNames(t *testing.T) {
+	layout := DefaultInputLayout()
+	layout.Students = SheetLayout{Start: "B3", SplitNames: FirstNameLastName}
+	layout.Constraints = SheetLayout{Start: "A2", SplitNames: LastNameFirstName}
+
+	students := testSheet{name: "Students", rows: [][]string{
+		{"Class 3A"},
+		{},
+		{"", "", "", "[Gender]"},
+		{"", "Ana", "Novak", "F"},
+		{"", "Bor", "Kos", "M"},
+		{"", "Cene", "Zupan", "M"},
+	}}
+	exclusions := testSheet{name: "Exclusions", rows: [][]string{
+		{"Apart"},
+		{"Novak", "Ana", "Zupan", "Cene"},
+		{"Kos", "Bor"},
+	}}
+
+	data, err := ReadExcelNumGroups(newWorkbook(t, students, exclusions), layout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(data.Students, []string{"Ana Novak", "Bor Kos", "Cene Zupan"}) {
+		t.Errorf("students %v", data.Students)
+	}
+	if len(data.Exclusions) != 2 || !slices.Equal(data.Exclusions[0], []string{"Ana Novak", "Bor Kos"}) || !slices.Equal(data.Exclusions[1], []string{"Cene Zupan"}) {
+		t.Errorf("exclusions %v", data.Exclusions)
+	}
+	if data.StudentAttributes["Bor Kos"]["Gender"] != "M" {
+		t.Errorf("attributes %v", data.StudentAttributes)
+	}
+
+	// Spaces around either half of a name are reported at the cell of the
+	// workbook, as the joined name no longer shows them.
+	students.rows[4][2] = "Kos "
+	_, err = ReadExcelNumGroups(newWorkbook(t, students, exclusions), layout)
+	if err == nil || !strings.Contains(err.Error(), "name at Students!C5 contains leading or trailing spaces") {
+		t.Errorf("got %v, want the spaces at Students!C5", err)
+	}
+}
@@ -0,0 +1,567 @@
+package excel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/types"
+)
+
+const (
+	errOpeningJSONFile = "Error opening JSON file:"
+	errSavingJSONFile  = "Error saving JSON file:"
+)
+
+// jsonProblem is the JSON form of types.GroupingData together with the
+// options of the run. Subject mode lists subjects, number-of-groups and
+// preference mode list students.
+type jsonProblem struct {
+	Subjects       []jsonSubject `json:"subjects,omitempty"`
+	Students       []string      `json:"students,omitempty"`
+	Exclusions     [][]string    `json:"exclusions,omitempty"`
+	Inclusions     [][]string    `json:"inclusions,omitempty"`
+	SoftExclusions [][]string    `json:"softExclusions,omitempty"`
+	SoftInclusions [][]string    `json:"softInclusions,omitempty"`
+	// FixedAssignments maps students to a group number or name.
+	FixedAssignments map[string]any `json:"fixedAssignments,omitempty"`
+	// NamedGroups are the groups of count and preference mode, in output
+	// order.
+	NamedGroups []jsonNamedGroup `json:"namedGroups,omitempty"`
+	// Rankings maps students to the group numbers or names they would like
+	// to be in, best first.
+	Rankings map[string][]any `json:"rankings,omitempty"`
+	// Nominations lists, for every nominating student, their name followed
+	// by the classmates they would like to work with.
+	Nominations       [][]string                   `json:"nominations,omitempty"`
+	Attributes        []string                     `json:"attributes,omitempty"`
+	StudentAttributes map[string]map[string]string `json:"studentAttributes,omitempty"`
+	Options           jsonOptions                  `json:"options"`
+}
+
+type jsonSubject struct {
+	Name     string   `json:"name"`
+	Students []string `json:"students"`
+}
+
+// jsonNamedGroup is a named group; a Capacity of 0 takes any number of
+// students.
+type jsonNamedGroup struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity,omitempty"`
+}
+
+type jsonOptions struct {
+	// Mode is "subject", "count" or "preference"; empty picks subject or
+	// count mode from the data.
+	Mode      string       `json:"mode,omitempty"`
+	NumGroups int          `json:"numGroups,omitempty"`
+	Seed      int64        `json:"seed,omitempty"`
+	Balance   bool         `json:"balance,omitempty"`
+	MinSize   int          `json:"minSize,omitempty"`
+	MaxSize   int          `json:"maxSize,omitempty"`
+	Weights   *jsonWeights `json:"weights,omitempty"`
+	// TimeBudget is a duration such as "5s".
+	TimeBudget string `json:"timeBudget,omitempty"`
+	// Alternatives is the number of distinct groupings to make, at least
+	// MinDifference students apart.
+	Alternatives  int `json:"alternatives,omitempty"`
+	MinDifference int `json:"minDifference,omitempty"`
+}
+
+// jsonWeights leaves out weights that keep their default value.
+type jsonWeights struct {
+	PreferApart      *float64 `json:"preferApart,omitempty"`
+	PreferTogether   *float64 `json:"preferTogether,omitempty"`
+	Rankings         *float64 `json:"rankings,omitempty"`
+	Nominations      *float64 `json:"nominations,omitempty"`
+	AttributeBalance *float64 `json:"attributeBalance,omitempty"`
+	RepeatPartners   *float64 `json:"repeatPartners,omitempty"`
+}
+
+// jsonSheets lays out a jsonProblem like a workbook, so that it is checked
+// by the same rules, and refers to cells by their place in the JSON document.
+type jsonSheets struct {
+	tableSheets
+	bySubject bool
+}
+
+var jsonConstraintKeys = []string{"", "exclusions", "inclusions", "softExclusions", "softInclusions"}
+
+func (j jsonSheets) cellName(sheetIndex int, colIndex int, rowIndex int) string {
+	switch {
+	case sheetIndex == assignmentsSheet:
+		return fmt.Sprintf("fixedAssignments[%q]", j.sheets[sheetIndex][rowIndex][0])
+	case sheetIndex == namedGroupsSheet && colIndex == 0:
+		return fmt.Sprintf("namedGroups[%d].name", rowIndex-1)
+	case sheetIndex == namedGroupsSheet:
+		return fmt.Sprintf("namedGroups[%d].capacity", rowIndex-1)
+	case sheetIndex == rankingsSheet && colIndex == 0:
+		return fmt.Sprintf("rankings[%q]", j.sheets[sheetIndex][rowIndex][0])
+	case sheetIndex == rankingsSheet:
+		return fmt.Sprintf("rankings[%q][%d]", j.sheets[sheetIndex][rowIndex][0], colIndex-1)
+	case sheetIndex == nominationsSheet:
+		return fmt.Sprintf("nominations[%d][%d]", colIndex, rowIndex)
+	case sheetIndex > 0:
+		return fmt.Sprintf("%s[%d][%d]", jsonConstraintKeys[sheetIndex], colIndex, rowIndex)
+	case !j.bySubject:
+		return fmt.Sprintf("students[%d]", rowIndex)
+	case rowIndex == 0:
+		return fmt.Sprintf("subjects[%d].name", colIndex)
+	default:
+		return fmt.Sprintf("subjects[%d].students[%d]", colIndex, rowIndex-1)
+	}
+}
+
+// ReadJSONProblem loads the data and options of a grouping problem from the
+// specified JSON file.
+func ReadJSONProblem(filename string) (*types.GroupingData, grouping.Options, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, grouping.Options{}, fmt.Errorf("%s %s\n%s", errOpeningJSONFile, err, errNotifyDeveloper)
+	}
+	defer file.Close()
+
+	return DecodeJSONProblem(file)
+}
+
+// DecodeJSONProblem reads a grouping problem in the JSON format of
+// ReadJSONProblem. Problems with the content wrap ErrInvalidInput.
+func DecodeJSONProblem(r io.Reader) (*types.GroupingData, grouping.Options, error) {
+	var problem jsonProblem
+	issues := &validationErrors{}
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&problem); err != nil {
+		issues.add("not a valid grouping problem: %s", err)
+		return nil, grouping.Options{}, issues.err()
+	}
+
+	opts, bySubject := problem.options(issues)
+	if err := issues.err(); err != nil {
+		return nil, grouping.Options{}, err
+	}
+
+	var data *types.GroupingData
+	var err error
+	sheets := problem.sheets(bySubject)
+	if bySubject {
+		data, err = readSubjectGroups(sheets)
+	} else {
+		data, err = readNumGroups(sheets)
+	}
+	if err != nil {
+		return nil, grouping.Options{}, err
+	}
+
+	if err := problem.readAttributes(data); err != nil {
+		return nil, grouping.Options{}, err
+	}
+
+	return data, opts, nil
+}
+
+// options checks the options and the shape of the problem and reports
+// whether it is a subject mode problem.
+func (p *jsonProblem) options(issues *validationErrors) (grouping.Options, bool) {
+	opts := grouping.Options{
+		NumGroups: p.Options.NumGroups,
+		Seed:      p.Options.Seed,
+		Balance:   p.Options.Balance,
+		MinSize:   p.Options.MinSize,
+		MaxSize:   p.Options.MaxSize,
+
+		Alternatives:  p.Options.Alternatives,
+		MinDifference: p.Options.MinDifference,
+	}
+
+	bySubject := len(p.Subjects) > 0
+	byPreference := false
+	switch p.Options.Mode {
+	case "":
+	case grouping.BySubject.String():
+		bySubject = true
+	case grouping.ByCount.String():
+		bySubject = false
+	case grouping.ByPreference.String():
+		bySubject, byPreference = false, true
+	default:
+		issues.add("options.mode %q must be %q, %q or %q", p.Options.Mode, grouping.BySubject, grouping.ByCount, grouping.ByPreference)
+	}
+
+	switch {
+	case bySubject && len(p.Students) > 0:
+		issues.add("students must be listed under subjects in subject mode")
+	case bySubject && len(p.Subjects) == 0:
+		issues.add("no subjects were found; subject mode needs at least one subject with students")
+	case !bySubject && len(p.Subjects) > 0:
+		issues.add("subjects cannot be used in count mode; list the students under students")
+	case !bySubject && len(p.Students) == 0:
+		issues.add("no students were found; count mode needs a list of students")
+	}
+	if bySubject && len(p.NamedGroups) > 0 {
+		issues.add("namedGroups cannot be used in subject mode, where every group has one student from each subject")
+	}
+	if bySubject && len(p.Rankings) > 0 {
+		issues.add("rankings cannot be used in subject mode; use preference mode with namedGroups")
+	}
+	if byPreference && len(p.NamedGroups) == 0 {
+		issues.add("no namedGroups were found; preference mode needs the groups the students rank")
+	}
+	for i, group := range p.NamedGroups {
+		if group.Name == "" {
+			issues.add("namedGroups[%d].name is empty", i)
+		}
+	}
+	switch {
+	case bySubject:
+		opts.Mode = grouping.BySubject
+	case byPreference:
+		opts.Mode = grouping.ByPreference
+	default:
+		opts.Mode = grouping.ByCount
+	}
+
+	for i, subject := range p.Subjects {
+		if _, ok := attributeHeader(subject.Name); ok {
+			issues.add("subject name %q at subjects[%d].name must not be in square brackets", subject.Name, i)
+		}
+	}
+
+	if p.Options.NumGroups < 0 {
+		issues.add("options.numGroups must not be negative")
+	}
+	switch {
+	case p.Options.MinSize < 0:
+		issues.add("options.minSize must not be negative")
+	case p.Options.MaxSize < 0:
+		issues.add("options.maxSize must not be negative")
+	case p.Options.MaxSize > 0 && p.Options.MinSize > p.Options.MaxSize:
+		issues.add("options.minSize %d must not be larger than options.maxSize %d", p.Options.MinSize, p.Options.MaxSize)
+	}
+	if p.Options.Alternatives < 0 {
+		issues.add("options.alternatives must not be negative")
+	}
+	if p.Options.MinDifference < 0 {
+		issues.add("options.minDifference must not be negative")
+	}
+
+	if p.Options.Weights != nil {
+		weights := grouping.DefaultWeights()
+		if p.Options.Weights.PreferApart != nil {
+			weights.PreferApart = *p.Options.Weights.PreferApart
+		}
+		if p.Options.Weights.PreferTogether != nil {
+			weights.PreferTogether = *p.Options.Weights.PreferTogether
+		}
+		if p.Options.Weights.Rankings != nil {
+			weights.Rankings = *p.Options.Weights.Rankings
+		}
+		if p.Options.Weights.Nominations != nil {
+			weights.Nominations = *p.Options.Weights.Nominations
+		}
+		if p.Options.Weights.AttributeBalance != nil {
+			weights.AttributeBalance = *p.Options.Weights.AttributeBalance
+		}
+		if p.Options.Weights.RepeatPartners != nil {
+			weights.RepeatPartners = *p.Options.Weights.RepeatPartners
+		}
+		opts.Weights = &weights
+	}
+
+	if p.Options.TimeBudget != "" {
+		budget, err := time.ParseDuration(p.Options.TimeBudget)
+		if err != nil || budget < 0 {
+			issues.add("options.timeBudget %q must be a duration such as \"5s\"", p.Options.TimeBudget)
+		}
+		opts.TimeBudget = budget
+	}
+
+	return opts, bySubject
+}
+
+// sheets lays out the problem like a workbook: subjects as columns with the
+// name in row 1, or students in column A, one constraint group per column on
+// the following sheets and on the nominations sheet, and the fixed
+// assignments, named groups and rankings below a header row.
+func (p *jsonProblem) sheets(bySubject bool) jsonSheets {
+	roster := make([][]string, 0)
+	if bySubject {
+		roster = append(roster, make([]string, len(p.Subjects)))
+		for colIndex, subject := range p.Subjects {
+			roster[0][colIndex] = subject.Name
+			for rowIndex, student := range subject.Students {
+				for len(roster) <= rowIndex+1 {
+					roster = append(roster, make([]string, len(p.Subjects)))
+				}
+				roster[rowIndex+1][colIndex] = student
+			}
+		}
+	} else {
+		for _, student := range p.Students {
+			roster = append(roster, []string{student})
+		}
+	}
+
+	sheets := [][][]string{roster}
+	for _, constraintGroups := range [][][]string{p.Exclusions, p.Inclusions, p.SoftExclusions, p.SoftInclusions} {
+		sheets = append(sheets, columnRows(constraintGroups))
+	}
+
+	// The header row keeps the first assignment from being taken for one.
+	assignments := [][]string{{studentHeader, groupHeader}}
+	for _, student := range sortedKeys(p.FixedAssignments) {
+		assignments = append(assignments, []string{student, fmt.Sprint(p.FixedAssignments[student])})
+	}
+	sheets = append(sheets, assignments)
+
+	namedGroups := [][]string{{groupHeader, capacityHeader}}
+	for _, group := range p.NamedGroups {
+		capacity := ""
+		if group.Capacity != 0 {
+			capacity = fmt.Sprint(group.Capacity)
+		}
+		namedGroups = append(namedGroups, []string{group.Name, capacity})
+	}
+	sheets = append(sheets, namedGroups)
+
+	rankings := [][]string{{studentHeader, choiceHeader}}
+	for _, student := range sortedKeys(p.Rankings) {
+		row := []string{student}
+		for _, group := range p.Rankings[student] {
+			row = append(row, fmt.Sprint(group))
+		}
+		rankings = append(rankings, row)
+	}
+	sheets = append(sheets, rankings)
+	sheets = append(sheets, columnRows(p.Nominations))
+
+	return jsonSheets{tableSheets: tableSheets{sheets: sheets}, bySubject: bySubject}
+}
+
+// columnRows lays out every list as a column of its own.
+func columnRows(columns [][]string) [][]string {
+	rows := make([][]string, 0)
+	for colIndex, column := range columns {
+		for rowIndex, student := range column {
+			for len(rows) <= rowIndex {
+				rows = append(rows, make([]string, len(columns)))
+			}
+			rows[rowIndex][colIndex] = student
+		}
+	}
+
+	return rows
+}
+
+// readAttributes checks the student attributes against the students in data
+// and stores them there.
+func (p *jsonProblem) readAttributes(data *types.GroupingData) error {
+	issues := &validationErrors{}
+	attributes := newStudentAttributes()
+
+	known := make(map[string]bool)
+	for i, name := range p.Attributes {
+		switch {
+		case trimmedValue(name) != name:
+			issues.add("attribute name at attributes[%d] contains leading or trailing spaces", i)
+		case name == "":
+			issues.add("attribute name at attributes[%d] is empty", i)
+		case known[name]:
+			issues.add("attribute %q is listed twice in attributes", name)
+		default:
+			known[name] = true
+			attributes.addName(name)
+		}
+	}
+
+	students := make(map[string]bool)
+	for _, student := range data.Students {
+		students[student] = true
+	}
+	for _, subjectStudents := range data.SubjectStudents {
+		for _, student := range subjectStudents {
+			students[student] = true
+		}
+	}
+
+	for _, student := range sortedKeys(p.StudentAttributes) {
+		if !students[student] {
+			issues.add("studentAttributes[%q] does not match any student", student)
+			continue
+		}
+
+		values := p.StudentAttributes[student]
+		for _, name := range sortedKeys(values) {
+			value := values[name]
+			switch {
+			case !known[name]:
+				issues.add("attribute %q of student %q is not listed in attributes", name, student)
+			case trimmedValue(value) != value:
+				issues.add("%s value of student %q contains leading or trailing spaces", name, student)
+			case value != "":
+				attributes.set(student, name, value)
+			}
+		}
+	}
+
+	if err := issues.err(); err != nil {
+		return err
+	}
+
+	data.Attributes = attributes.names
+	data.StudentAttributes = attributes.values
+	return nil
+}
+
+// jsonResult is the JSON form of grouping.Result.
+type jsonResult struct {
+	Groups [][]string `json:"groups"`
+	// GroupNames is only written when the problem names its groups.
+	GroupNames []string        `json:"groupNames,omitempty"`
+	Seed       int64           `json:"seed"`
+	Penalty    float64         `json:"penalty"`
+	Violations []jsonViolation `json:"violations"`
+	// UnmetWishes is only written when students nominated classmates.
+	UnmetWishes []jsonUnmetWish `json:"unmetWishes,omitempty"`
+	Attributes  []jsonAttribute `json:"attributes,omitempty"`
+	// RepeatPartners is only written when earlier groupings were given.
+	RepeatPartners []jsonRepeatPartners `json:"repeatPartners,omitempty"`
+	// Satisfaction is only written in preference mode.
+	Satisfaction *jsonSatisfaction `json:"satisfaction,omitempty"`
+	Diagnostics  jsonDiagnostics   `json:"diagnostics"`
+	// Alternatives holds the other options, best first, when several were
+	// asked for.
+	Alternatives []jsonResult `json:"alternatives,omitempty"`
+}
+
+// jsonSatisfaction counts the students who got their first, second, ...
+// choice, and tells every student's choice, 0 for a group they did not rank.
+type jsonSatisfaction struct {
+	Choices  []int          `json:"choices"`
+	Unranked int            `json:"unranked"`
+	Students map[string]int `json:"students"`
+}
+
+type jsonUnmetWish struct {
+	Student string   `json:"student"`
+	Wishes  []string `json:"wishes"`
+}
+
+type jsonViolation struct {
+	Kind     string    `json:"kind"`
+	Students [2]string `json:"students"`
+	Message  string    `json:"message"`
+}
+
+type jsonAttribute struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+	// Counts[g][v] is the number of students in group g with value Values[v].
+	Counts [][]int `json:"counts"`
+}
+
+type jsonRepeatPartners struct {
+	Student  string   `json:"student"`
+	Partners []string `json:"partners"`
+}
+
+type jsonDiagnostics struct {
+	Units            int      `json:"units"`
+	ConstrainedUnits int      `json:"constrainedUnits"`
+	ElapsedSeconds   float64  `json:"elapsedSeconds"`
+	Notes            []string `json:"notes"`
+}
+
+// ExportToJSON exports the results to a JSON file: the first at the top
+// level and the others, the alternatives to it, under "alternatives".
+func ExportToJSON(results []*grouping.Result, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("%s %s\n%s", errSavingJSONFile, err, errNotifyDeveloper)
+	}
+	defer file.Close()
+
+	if err := EncodeJSONResult(file, results); err != nil {
+		return fmt.Errorf("%s %s\n%s", errSavingJSONFile, err, errNotifyDeveloper)
+	}
+
+	return file.Close()
+}
+
+// EncodeJSONResult writes the results in the JSON format of ExportToJSON.
+func EncodeJSONResult(w io.Writer, results []*grouping.Result) error {
+	out := newJSONResult(results[0])
+	for _, result := range results[1:] {
+		out.Alternatives = append(out.Alternatives, newJSONResult(result))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func newJSONResult(result *grouping.Result) jsonResult {
+	out := jsonResult{
+		Groups:     make([][]string, len(result.Groups)),
+		GroupNames: result.GroupNames,
+		Seed:       result.Seed,
+		Penalty:    result.Penalty,
+		Violations: make([]jsonViolation, 0, len(result.Violations)),
+		Diagnostics: jsonDiagnostics{
+			Units:            result.Diagnostics.Units,
+			ConstrainedUnits: result.Diagnostics.ConstrainedUnits,
+			ElapsedSeconds:   result.Diagnostics.Elapsed.Seconds(),
+			Notes:            result.Diagnostics.Notes,
+		},
+	}
+	for i, group := range result.Groups {
+		out.Groups[i] = append(make([]string, 0, len(group)), group...)
+	}
+	for _, violation := range result.Violations {
+		out.Violations = append(out.Violations, jsonViolation{
+			Kind:     violation.Kind.String(),
+			Students: violation.Students,
+			Message:  violation.String(),
+		})
+	}
+	for _, wish := range result.UnmetWishes {
+		out.UnmetWishes = append(out.UnmetWishes, jsonUnmetWish{Student: wish.Student, Wishes: wish.Wishes})
+	}
+	for _, attribute := range result.Attributes {
+		out.Attributes = append(out.Attributes, jsonAttribute{
+			Attribute: attribute.Attribute,
+			Values:    attribute.Values,
+			Counts:    attribute.Counts,
+		})
+	}
+	for _, repeat := range result.RepeatPartners {
+		out.RepeatPartners = append(out.RepeatPartners, jsonRepeatPartners{Student: repeat.Student, Partners: repeat.Partners})
+	}
+	if satisfaction := result.Satisfaction; satisfaction != nil {
+		out.Satisfaction = &jsonSatisfaction{Choices: satisfaction.Choices, Unranked: satisfaction.Unranked, Students: satisfaction.StudentChoices}
+		if out.Satisfaction.Choices == nil {
+			out.Satisfaction.Choices = make([]int, 0)
+		}
+	}
+	if out.Diagnostics.Notes == nil {
+		out.Diagnostics.Notes = make([]string, 0)
+	}
+
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
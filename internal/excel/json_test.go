@@ -0,0 +1,115 @@
+package excel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kremec/edugroup/grouping"
+)
+
+func TestDecodeJSONProblem(t *testing.T) {
+	problem := `{
+		"students": ["Ana", "Bor", "Cene", "Dana"],
+		"exclusions": [["Ana", "Bor"]],
+		"options": {"numGroups": 2, "seed": 7, "timeBudget": "2s"}
+	}`
+
+	data, opts, err := DecodeJSONProblem(strings.NewReader(problem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(data.Students, []string{"Ana", "Bor", "Cene", "Dana"}) {
+		t.Errorf("students %v", data.Students)
+	}
+	if len(data.Exclusions) != 1 || !slices.Equal(data.Exclusions[0], []string{"Ana", "Bor"}) {
+		t.Errorf("exclusions %v", data.Exclusions)
+	}
+	if opts.Mode != grouping.ByCount || opts.NumGroups != 2 || opts.Seed != 7 || opts.TimeBudget.Seconds() != 2 {
+		t.Errorf("options %+v", opts)
+	}
+}
+
+func TestDecodeJSONProblemIssues(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem string
+		issue   string
+	}{
+		{
+			name:    "unknown field",
+			problem: `{"students": ["Ana"], "groups": 2}`,
+			issue:   "not a valid grouping problem",
+		},
+		{
+			name:    "unknown student",
+			problem: `{"students": ["Ana", "Bor"], "exclusions": [["Ana", "Cene"]], "options": {"numGroups": 2}}`,
+			issue:   "exclusions[0][1]",
+		},
+		{
+			name:    "negative minimum size",
+			problem: `{"students": ["Ana", "Bor"], "options": {"numGroups": 2, "minSize": -1}}`,
+			issue:   "options.minSize must not be negative",
+		},
+		{
+			name:    "subjects in count mode",
+			problem: `{"subjects": [{"name": "Math", "students": ["Ana"]}], "options": {"mode": "count", "numGroups": 2}}`,
+			issue:   "subjects cannot be used in count mode",
+		},
+		{
+			name:    "time budget",
+			problem: `{"students": ["Ana", "Bor"], "options": {"numGroups": 2, "timeBudget": "soon"}}`,
+			issue:   `options.timeBudget "soon"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := DecodeJSONProblem(strings.NewReader(test.problem))
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("got %v, want invalid input", err)
+			}
+			var inputErr *InputError
+			if !errors.As(err, &inputErr) {
+				t.Fatalf("got %T, want *InputError", err)
+			}
+			if !slices.ContainsFunc(inputErr.Issues, func(issue string) bool { return strings.Contains(issue, test.issue) }) {
+				t.Errorf("issues %q do not mention %q", inputErr.Issues, test.issue)
+			}
+		})
+	}
+}
+
+func TestEncodeJSONResultEmptyGroups(t *testing.T) {
+	results := []*grouping.Result{
+		{Groups: [][]string{{"Ana"}, {"Bor"}, nil}},
+		{Groups: [][]string{{"Bor"}, nil, {"Ana"}}},
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeJSONResult(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "null") {
+		t.Errorf("result has null values:\n%s", buf.String())
+	}
+
+	var out struct {
+		Groups       [][]string `json:"groups"`
+		Alternatives []struct {
+			Groups [][]string `json:"groups"`
+		} `json:"alternatives"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Groups) != 3 || out.Groups[2] == nil || len(out.Groups[2]) != 0 {
+		t.Errorf("groups %#v, want an empty third group", out.Groups)
+	}
+	if len(out.Alternatives) != 1 || out.Alternatives[0].Groups[1] == nil {
+		t.Errorf("alternatives %#v, want an empty second group", out.Alternatives)
+	}
+}
@@ -0,0 +1,257 @@
+package excel
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kremec/edugroup/types"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Layout selects how ExportToExcel arranges the groups on the groups sheet.
+type Layout int
+
+const (
+	// LayoutRows writes every group to a row, starting with the group name.
+	LayoutRows Layout = iota
+	// LayoutColumns writes every group to a column under a header with the
+	// group name.
+	LayoutColumns
+	// LayoutTable writes one row per student with their group, subject and
+	// attributes, formatted as an Excel table with an auto filter.
+	LayoutTable
+)
+
+func (l Layout) String() string {
+	switch l {
+	case LayoutRows:
+		return "rows"
+	case LayoutColumns:
+		return "columns"
+	case LayoutTable:
+		return "table"
+	default:
+		return fmt.Sprintf("Layout(%d)", int(l))
+	}
+}
+
+// ParseLayout returns the layout with the given name, as printed by
+// Layout.String.
+func ParseLayout(name string) (Layout, error) {
+	for _, layout := range []Layout{LayoutRows, LayoutColumns, LayoutTable} {
+		if strings.EqualFold(strings.TrimSpace(name), layout.String()) {
+			return layout, nil
+		}
+	}
+
+	return LayoutRows, fmt.Errorf("unknown layout %q, expected \"rows\", \"columns\" or \"table\"", name)
+}
+
+const (
+	subjectHeader = "Subject"
+	studentHeader = "Student"
+	groupHeader   = "Group"
+	sizeHeader    = "Size"
+	optionHeader  = "Option"
+	penaltyHeader = "Penalty (lower is better)"
+	groupsTable   = "GroupsTable"
+)
+
+func groupName(groupIndex int) string {
+	return "Group " + strconv.Itoa(groupIndex+1)
+}
+
+// outputGroupNames returns the name of each of count groups: the name given
+// in names, or the numbered name of groupName.
+func outputGroupNames(names []string, count int) []string {
+	labels := make([]string, count)
+	for i := range labels {
+		if i < len(names) && names[i] != "" {
+			labels[i] = names[i]
+		} else {
+			labels[i] = groupName(i)
+		}
+	}
+
+	return labels
+}
+
+// isGroupName reports whether value names a group, either numbered or as one
+// of names.
+func isGroupName(value string, names []string) bool {
+	return strings.HasPrefix(value, "Group ") || slices.Contains(names, value)
+}
+
+// writeGroupRows writes every group to a row of sheet. In subject mode every
+// group row is followed by a row with the subject of each student. It has no
+// group name, so it is not read back as a group.
+func writeGroupRows(f *excelize.File, sheet string, groups [][]string, names []string, studentSubject map[string]string) {
+	rowIndex := 0
+	for i, group := range groups {
+		f.SetCellValue(sheet, spreadsheetCell(0, rowIndex), names[i])
+		for j, student := range group {
+			f.SetCellValue(sheet, spreadsheetCell(j+1, rowIndex), student)
+		}
+		rowIndex++
+
+		if len(studentSubject) > 0 {
+			for j, student := range group {
+				f.SetCellValue(sheet, spreadsheetCell(j+1, rowIndex), studentSubject[student])
+			}
+			rowIndex++
+		}
+	}
+}
+
+// writeGroupColumns writes every group to a column of sheet with the group
+// name in the first row. In subject mode the subjects of the students are in
+// the column right of the group, under a "Subject" header.
+func writeGroupColumns(f *excelize.File, sheet string, groups [][]string, names []string, studentSubject map[string]string) {
+	colIndex := 0
+	for i, group := range groups {
+		f.SetCellValue(sheet, spreadsheetCell(colIndex, 0), names[i])
+		for j, student := range group {
+			f.SetCellValue(sheet, spreadsheetCell(colIndex, j+1), student)
+		}
+		colIndex++
+
+		if len(studentSubject) > 0 {
+			f.SetCellValue(sheet, spreadsheetCell(colIndex, 0), subjectHeader)
+			for j, student := range group {
+				f.SetCellValue(sheet, spreadsheetCell(colIndex, j+1), studentSubject[student])
+			}
+			colIndex++
+		}
+	}
+}
+
+// writeGroupTable writes one row per student to sheet with the group, the
+// subject in subject mode and the attributes of data, and formats it as the
+// named table.
+func writeGroupTable(f *excelize.File, sheet string, table string, groups [][]string, names []string, studentSubject map[string]string, data *types.GroupingData) error {
+	headers := []string{studentHeader, groupHeader}
+	if len(studentSubject) > 0 {
+		headers = append(headers, subjectHeader)
+	}
+	var attributes []string
+	if data != nil {
+		attributes = data.Attributes
+		headers = append(headers, attributes...)
+	}
+	for colIndex, header := range headers {
+		f.SetCellValue(sheet, spreadsheetCell(colIndex, 0), header)
+	}
+
+	rowIndex := 1
+	for i, group := range groups {
+		for _, student := range group {
+			values := []string{student, names[i]}
+			if len(studentSubject) > 0 {
+				values = append(values, studentSubject[student])
+			}
+			for _, attribute := range attributes {
+				values = append(values, data.StudentAttributes[student][attribute])
+			}
+			for colIndex, value := range values {
+				f.SetCellValue(sheet, spreadsheetCell(colIndex, rowIndex), value)
+			}
+			rowIndex++
+		}
+	}
+
+	// A table needs at least one row below the header
+	lastRow := max(rowIndex-1, 1)
+	return f.AddTable(sheet, &excelize.Table{
+		Range:     spreadsheetCell(0, 0) + ":" + spreadsheetCell(len(headers)-1, lastRow),
+		Name:      table,
+		StyleName: "TableStyleMedium2",
+	})
+}
+
+// readGroupsSheet reads the groups back from a groups sheet in any of the
+// layouts written by ExportToExcel, with the group names of the summary
+// sheet when the groups were named.
+func readGroupsSheet(rows [][]string, names []string) [][]string {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	header := rows[0]
+	switch {
+	case len(header) > 1 && trimmedValue(header[0]) == studentHeader && trimmedValue(header[1]) == groupHeader:
+		return groupTable(rows)
+	case isGroupColumns(rows, names):
+		return groupColumns(rows, names)
+	default:
+		return groupRows(rows, names)
+	}
+}
+
+// isGroupColumns tells the columns layout from the rows layout, in which the
+// first cell below a group name is always another group name or empty.
+func isGroupColumns(rows [][]string, names []string) bool {
+	for _, cell := range rows[0][1:] {
+		value := trimmedValue(cell)
+		if isGroupName(value, names) || value == subjectHeader {
+			return true
+		}
+	}
+
+	if len(rows) < 2 || len(rows[1]) == 0 {
+		return false
+	}
+	below := trimmedValue(rows[1][0])
+	return below != "" && !isGroupName(below, names)
+}
+
+// groupColumns reads the columns of a groups sheet that have a group name in
+// the first row.
+func groupColumns(rows [][]string, names []string) [][]string {
+	groups := make([][]string, 0, len(rows[0]))
+	for colIndex, cell := range rows[0] {
+		if !isGroupName(trimmedValue(cell), names) {
+			continue
+		}
+
+		group := make([]string, 0, len(rows)-1)
+		for _, row := range rows[1:] {
+			if colIndex < len(row) {
+				if student := trimmedValue(row[colIndex]); student != "" {
+					group = append(group, student)
+				}
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups
+}
+
+// groupTable reads a table with one row per student and their group in the
+// second column, keeping the groups in order of first appearance.
+func groupTable(rows [][]string) [][]string {
+	groups := make([][]string, 0)
+	groupIndex := make(map[string]int)
+	for _, row := range rows[1:] {
+		if len(row) < 2 {
+			continue
+		}
+		student, group := trimmedValue(row[0]), trimmedValue(row[1])
+		if student == "" || group == "" {
+			continue
+		}
+
+		index, exists := groupIndex[group]
+		if !exists {
+			index = len(groups)
+			groupIndex[group] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], student)
+	}
+
+	return groups
+}
@@ -0,0 +1,79 @@
+package excel
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/types"
+)
+
+func TestGroupsSheetRoundTrip(t *testing.T) {
+	countData := &types.GroupingData{Students: []string{"Ana", "Bor", "Cene", "Dana", "Eva"}}
+	subjectData := &types.GroupingData{
+		Subjects:        []string{"Math", "Art"},
+		SubjectStudents: map[string][]string{"Math": {"Ana", "Bor"}, "Art": {"Cene", "Dana", "Eva"}},
+	}
+	tests := []struct {
+		name    string
+		mode    grouping.Mode
+		data    *types.GroupingData
+		groups  [][]string
+		names   []string
+		layouts []Layout
+	}{
+		{
+			name:   "numbered groups",
+			mode:   grouping.ByCount,
+			data:   countData,
+			groups: [][]string{{"Ana", "Bor", "Cene"}, {"Dana"}, {"Eva"}},
+		},
+		{
+			name:   "named groups",
+			mode:   grouping.ByCount,
+			data:   countData,
+			groups: [][]string{{"Ana"}, {"Bor", "Cene"}, {"Dana", "Eva"}},
+			names:  []string{"Lab", "Library", "Hall"},
+		},
+		{
+			name:   "subject groups",
+			mode:   grouping.BySubject,
+			data:   subjectData,
+			groups: [][]string{{"Ana", "Cene"}, {"Bor", "Dana"}, {"Eva"}},
+		},
+	}
+
+	for _, test := range tests {
+		for _, layout := range []Layout{LayoutRows, LayoutColumns, LayoutTable} {
+			t.Run(fmt.Sprintf("%s in %s", test.name, layout), func(t *testing.T) {
+				filename := filepath.Join(t.TempDir(), "groups.xlsx")
+				summary := Summary{Mode: test.mode, NumGroups: len(test.groups), GroupNames: test.names, Data: test.data}
+				if err := ExportToExcel([]Option{{Groups: test.groups}}, summary, layout, filename); err != nil {
+					t.Fatal(err)
+				}
+
+				groups, err := readEarlierGroups(filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.EqualFunc(groups, test.groups, slices.Equal) {
+					t.Errorf("read %v, want %v", groups, test.groups)
+				}
+			})
+		}
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	for _, layout := range []Layout{LayoutRows, LayoutColumns, LayoutTable} {
+		parsed, err := ParseLayout(layout.String())
+		if err != nil || parsed != layout {
+			t.Errorf("ParseLayout(%q) = %v, %v", layout.String(), parsed, err)
+		}
+	}
+	if _, err := ParseLayout("grid"); err == nil {
+		t.Error("unknown layout \"grid\" was accepted")
+	}
+}
@@ -0,0 +1,100 @@
+package excel
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kremec/edugroup/types"
+)
+
+// capacityHeader is the header of the capacity column of the named groups
+// sheet.
+const capacityHeader = "Capacity"
+
+// getNamedGroups reads the named groups sheet: a group name in column A and
+// the most students the group takes in column B, which may be empty for no
+// limit. A first row whose column B holds text or whose column A is "Group"
+// is a header row. The groups are returned in the order of the sheet.
+func getNamedGroups(f sheetSource) ([]types.NamedGroup, error) {
+	// If the sheet is missing, the groups are numbered.
+	if !f.hasSheet(namedGroupsSheet) {
+		return nil, nil
+	}
+
+	rows, err := f.getRows(namedGroupsSheet)
+	if err != nil {
+		return nil, sheetError(err)
+	}
+
+	issues := &validationErrors{}
+	groups := make([]types.NamedGroup, 0)
+	seen := make(map[string]string)
+	for rowIndex, row := range rows {
+		rawName, rawCapacity := cellValue(row, 0), cellValue(row, 1)
+		name, capacity := trimmedValue(rawName), trimmedValue(rawCapacity)
+		if rowIndex == 0 {
+			if _, err := strconv.Atoi(capacity); strings.EqualFold(name, groupHeader) || (capacity != "" && err != nil) {
+				continue
+			}
+		}
+
+		nameCell := cellReference(f, namedGroupsSheet, 0, rowIndex)
+		capacityCell := cellReference(f, namedGroupsSheet, 1, rowIndex)
+		for colIndex := 2; colIndex < len(row); colIndex++ {
+			if trimmedValue(row[colIndex]) != "" {
+				issues.add("%s contains %q, but named groups only take a group name in %s and a capacity in %s", cellReference(f, namedGroupsSheet, colIndex, rowIndex), row[colIndex], columnOf(nameCell), columnOf(capacityCell))
+			}
+		}
+		if rawName != name {
+			issues.add("group name at %s contains leading or trailing spaces", nameCell)
+		}
+
+		switch {
+		case name == "" && capacity == "":
+			continue
+		case name == "":
+			issues.add("%s gives capacity %q to no group; add the group's name in %s", rowOf(capacityCell), capacity, nameCell)
+			continue
+		}
+
+		group := types.NamedGroup{Name: name}
+		if capacity != "" {
+			group.Capacity, err = strconv.Atoi(capacity)
+			if err != nil || group.Capacity < 1 {
+				issues.add("capacity %q of group %q at %s must be a positive whole number, or empty for no limit", capacity, name, capacityCell)
+				continue
+			}
+		}
+
+		if first, exists := seen[strings.ToLower(name)]; exists {
+			issues.add("group %q is named twice, at %s and %s", name, first, nameCell)
+			continue
+		}
+
+		seen[strings.ToLower(name)] = nameCell
+		groups = append(groups, group)
+	}
+
+	if err := issues.err(); err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	return groups, nil
+}
+
+// namedGroupNames returns the names of groups, or nil without any.
+func namedGroupNames(groups []types.NamedGroup) []string {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = group.Name
+	}
+
+	return names
+}
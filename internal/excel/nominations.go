@@ -0,0 +1,91 @@
+package excel
+
+import (
+	"strings"
+)
+
+// getNominations reads the nominations sheet: one column per nominating
+// student, with their name on top and the classmates they would like to work
+// with below it. Every entry of the result starts with the nominating
+// student, followed by their wishes.
+func getNominations(f sheetSource, knownStudents []string) ([][]string, error) {
+	// If the sheet is missing, nobody nominated anyone.
+	if !f.hasSheet(nominationsSheet) {
+		return make([][]string, 0), nil
+	}
+
+	columns, err := f.getCols(nominationsSheet)
+	if err != nil {
+		return nil, sheetError(err)
+	}
+
+	issues := &validationErrors{}
+	knownStudentsNormalized := make(map[string]string, len(knownStudents))
+	for _, student := range knownStudents {
+		knownStudentsNormalized[strings.ToLower(student)] = student
+	}
+
+	seenNominators := make(map[string]string)
+	nominations := make([][]string, 0, len(columns))
+	for colIndex, column := range columns {
+		nomination := make([]string, 0, len(column))
+		seenInColumn := make(map[string]string)
+
+		for rowIndex, rawName := range column {
+			cell := cellReference(f, nominationsSheet, colIndex, rowIndex)
+			name := trimmedValue(rawName)
+
+			if rawName != "" && rawName != name {
+				issues.add("nomination name at %s contains leading or trailing spaces", cell)
+			}
+
+			if name == "" {
+				continue
+			}
+
+			canonicalName, exists := knownStudentsNormalized[strings.ToLower(name)]
+			switch {
+			case !exists:
+				issues.add("nomination name %q at %s does not match any student from the students sheet", name, cell)
+				continue
+			case canonicalName != name:
+				issues.add("nomination name %q at %s must match the students-sheet name exactly: %q", name, cell, canonicalName)
+				continue
+			}
+
+			// The first name of the column is the nominating student.
+			if len(nomination) == 0 {
+				if first, exists := seenNominators[name]; exists {
+					issues.add("student %q nominates classmates in two columns, at %s and %s", name, first, cell)
+					break
+				}
+				seenNominators[name] = cell
+				seenInColumn[name] = cell
+				nomination = append(nomination, name)
+				continue
+			}
+
+			if first, exists := seenInColumn[name]; exists {
+				if name == nomination[0] {
+					issues.add("student %q nominates themselves at %s", name, cell)
+				} else {
+					issues.add("student %q is nominated twice by %q, at %s and %s", name, nomination[0], first, cell)
+				}
+				continue
+			}
+
+			seenInColumn[name] = cell
+			nomination = append(nomination, name)
+		}
+
+		if len(nomination) > 1 {
+			nominations = append(nominations, nomination)
+		}
+	}
+
+	if err := issues.err(); err != nil {
+		return nil, err
+	}
+
+	return nominations, nil
+}
@@ -0,0 +1,136 @@
+package excel
+
+import (
+	"strings"
+
+	"github.com/kremec/edugroup/grouping"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// choiceHeader is the header of the choice columns of the rankings sheet.
+const choiceHeader = "Choice"
+
+// getRankings reads the rankings sheet: a student name in column A followed
+// by the groups they would like to be in, best first, each as a group number
+// such as 2 or a group name such as one of names, the names of the named
+// groups. A first row whose column B holds no group is a header row. It
+// returns the zero-based group indexes ranked by every student.
+func getRankings(f sheetSource, knownStudents []string, names []string) (map[string][]int, error) {
+	rankings := make(map[string][]int)
+
+	// If the sheet is missing, nobody ranked the groups.
+	if !f.hasSheet(rankingsSheet) {
+		return rankings, nil
+	}
+
+	rows, err := f.getRows(rankingsSheet)
+	if err != nil {
+		return nil, sheetError(err)
+	}
+
+	issues := &validationErrors{}
+	seen := make(map[string]string)
+	knownStudentsNormalized := make(map[string]string, len(knownStudents))
+	for _, student := range knownStudents {
+		knownStudentsNormalized[strings.ToLower(student)] = student
+	}
+
+	for rowIndex, row := range rows {
+		rawName := cellValue(row, 0)
+		name := trimmedValue(rawName)
+		if rowIndex == 0 {
+			if _, isGroup := parseGroupRef(trimmedValue(cellValue(row, 1)), names); !isGroup {
+				continue
+			}
+		}
+
+		nameCell := cellReference(f, rankingsSheet, 0, rowIndex)
+		if rawName != name {
+			issues.add("ranking name at %s contains leading or trailing spaces", nameCell)
+		}
+		if name == "" {
+			if countNonEmptyCells(row) > 0 {
+				issues.add("%s ranks groups for nobody; add the student's name in %s", rowOf(nameCell), nameCell)
+			}
+			continue
+		}
+
+		canonicalName, exists := knownStudentsNormalized[strings.ToLower(name)]
+		switch {
+		case !exists:
+			issues.add("ranking name %q at %s does not match any student from the students sheet", name, nameCell)
+			continue
+		case canonicalName != name:
+			issues.add("ranking name %q at %s must match the students-sheet name exactly: %q", name, nameCell, canonicalName)
+			continue
+		}
+
+		if first, exists := seen[name]; exists {
+			issues.add("student %q ranks the groups twice, at %s and %s", name, first, nameCell)
+			continue
+		}
+		seen[name] = nameCell
+
+		ranking := make([]int, 0, len(row)-1)
+		rankedAt := make(map[int]string)
+		for colIndex := 1; colIndex < len(row); colIndex++ {
+			group := trimmedValue(row[colIndex])
+			groupCell := cellReference(f, rankingsSheet, colIndex, rowIndex)
+			if group == "" {
+				continue
+			}
+
+			groupIndex, isGroup := parseGroupRef(group, names)
+			if !isGroup {
+				example := groupName(1)
+				if len(names) > 0 {
+					example = names[0]
+				}
+				issues.add("choice %q of student %q at %s must be a group number such as 2 or a group name such as %q", group, name, groupCell, example)
+				continue
+			}
+			if !groupInRange(groupIndex, names) {
+				issues.add("choice %d of student %q at %s does not exist; there are only %d named groups", groupIndex+1, name, groupCell, len(names))
+				continue
+			}
+			if first, exists := rankedAt[groupIndex]; exists {
+				issues.add("student %q ranks group %q twice, at %s and %s", name, group, first, groupCell)
+				continue
+			}
+
+			rankedAt[groupIndex] = groupCell
+			ranking = append(ranking, groupIndex)
+		}
+		rankings[name] = ranking
+	}
+
+	if err := issues.err(); err != nil {
+		return nil, err
+	}
+
+	return rankings, nil
+}
+
+// writeSatisfactionSheet lists every student with their group and which of
+// their choices it was.
+func writeSatisfactionSheet(f *excelize.File, groups [][]string, names []string, satisfaction *grouping.Satisfaction) error {
+	if _, err := f.NewSheet(satisfactionSheetName); err != nil {
+		return err
+	}
+
+	f.SetCellValue(satisfactionSheetName, "A1", studentHeader)
+	f.SetCellValue(satisfactionSheetName, "B1", groupHeader)
+	f.SetCellValue(satisfactionSheetName, "C1", choiceHeader)
+	rowIndex := 1
+	for groupIndex, group := range groups {
+		for _, student := range group {
+			f.SetCellValue(satisfactionSheetName, spreadsheetCell(0, rowIndex), student)
+			f.SetCellValue(satisfactionSheetName, spreadsheetCell(1, rowIndex), names[groupIndex])
+			f.SetCellValue(satisfactionSheetName, spreadsheetCell(2, rowIndex), grouping.ChoiceLabel(satisfaction.StudentChoices[student]))
+			rowIndex++
+		}
+	}
+
+	return nil
+}
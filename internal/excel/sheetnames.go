@@ -0,0 +1,183 @@
+package excel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheet roles, numbered by the position of the sheet in a workbook whose
+// sheets are not recognized by name. Only the first positionalSheets roles
+// are read by position.
+const (
+	rosterSheet = iota
+	exclusionsSheet
+	inclusionsSheet
+	softExclusionsSheet
+	softInclusionsSheet
+	assignmentsSheet
+	namedGroupsSheet
+	rankingsSheet
+	nominationsSheet
+	sheetRoleCount
+)
+
+// positionalSheets is the number of sheets read by position: the students,
+// exclusions and inclusions sheets, which workbooks have always held in this
+// order. Sheets after them, such as notes, are not read as constraints.
+const positionalSheets = inclusionsSheet + 1
+
+// sheetRoles are the names of the roles, as used by SheetNames.Add.
+var sheetRoles = [sheetRoleCount]string{"students", "exclusions", "inclusions", "soft-exclusions", "soft-inclusions", "fixed-assignments", "named-groups", "rankings", "nominations"}
+
+// SheetNames lists the sheet names every input sheet is recognized by. Names
+// are compared ignoring case, surrounding spaces, and the difference between
+// spaces, dashes and underscores.
+type SheetNames struct {
+	Students       []string `json:"students"`
+	Exclusions     []string `json:"exclusions"`
+	Inclusions     []string `json:"inclusions"`
+	SoftExclusions []string `json:"softExclusions"`
+	SoftInclusions []string `json:"softInclusions"`
+	// FixedAssignments lists the names of the sheet that fixes students to
+	// groups.
+	FixedAssignments []string `json:"fixedAssignments"`
+	// NamedGroups lists the names of the sheet that names the groups and
+	// their capacities.
+	NamedGroups []string `json:"namedGroups"`
+	// Rankings lists the names of the sheet on which students rank the
+	// named groups.
+	Rankings []string `json:"rankings"`
+	// Nominations lists the names of the sheet on which students nominate
+	// the classmates they would like to work with.
+	Nominations []string `json:"nominations"`
+}
+
+// DefaultSheetNames returns the English names of the input sheets, with
+// Slovenian and German aliases.
+func DefaultSheetNames() SheetNames {
+	return SheetNames{
+		Students:         []string{"Students", "Roster", "Dijaki", "Učenci", "Schüler"},
+		Exclusions:       []string{"Exclusions", "Exceptions", "Izključitve", "Izjeme", "Ausschlüsse"},
+		Inclusions:       []string{"Inclusions", "Required", "Vključitve", "Skupaj", "Zusammen"},
+		SoftExclusions:   []string{"Prefer apart", "Soft exclusions", "Raje narazen", "Lieber getrennt"},
+		SoftInclusions:   []string{"Prefer together", "Soft inclusions", "Raje skupaj", "Lieber zusammen"},
+		FixedAssignments: []string{"Fixed assignments", "Fixed groups", "Pinned", "Fiksne skupine", "Dodelitve", "Feste Gruppen", "Zuordnungen"},
+		NamedGroups:      []string{"Named groups", "Capacities", "Stations", "Topics", "Imenovane skupine", "Kapacitete", "Teme", "Gruppennamen", "Kapazitäten", "Themen"},
+		Rankings:         []string{"Rankings", "Choices", "Ranked choices", "Izbire", "Vrstni red", "Wahlen", "Rangfolge"},
+		Nominations:      []string{"Nominations", "Wishes", "Wants to work with", "Želje", "Sodelavci", "Wünsche", "Wunschpartner"},
+	}
+}
+
+// Add recognizes the sheet called name as the sheet of role, which is one of
+// "students", "exclusions", "inclusions", "soft-exclusions",
+// "soft-inclusions", "fixed-assignments", "named-groups", "rankings" and
+// "nominations".
+func (n *SheetNames) Add(role string, name string) error {
+	names := n.byRole()
+	for index, roleName := range sheetRoles {
+		if strings.EqualFold(strings.TrimSpace(role), roleName) {
+			*names[index] = append(*names[index], name)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown sheet role %q, expected one of %s", role, strings.Join(sheetRoles[:], ", "))
+}
+
+// Extend recognizes the sheets of other in addition to those of n.
+func (n *SheetNames) Extend(other SheetNames) {
+	names, otherNames := n.byRole(), other.byRole()
+	for index := range names {
+		*names[index] = append(*names[index], *otherNames[index]...)
+	}
+}
+
+func (n *SheetNames) byRole() [sheetRoleCount]*[]string {
+	return [sheetRoleCount]*[]string{&n.Students, &n.Exclusions, &n.Inclusions, &n.SoftExclusions, &n.SoftInclusions, &n.FixedAssignments, &n.NamedGroups, &n.Rankings, &n.Nominations}
+}
+
+// SheetRole tells which sheet of a workbook was read for which input.
+type SheetRole struct {
+	// Role is one of the roles accepted by SheetNames.Add.
+	Role string
+	// Sheet is the name of the sheet, or "" when the workbook has none for
+	// the role.
+	Sheet string
+	// ByName is false when the sheet was picked by its position, because no
+	// sheet of the workbook had a known name.
+	ByName bool
+}
+
+// ReadSheetRoles returns which sheets of the workbook ReadExcelSubjectGroups
+// and ReadExcelNumGroups read for which role.
+func ReadSheetRoles(filename string, names SheetNames) ([]SheetRole, error) {
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s\n%s", errOpeningExcelFile, err, errNotifyDeveloper)
+	}
+	defer f.Close()
+
+	sheets, err := newWorkbookSheets(f, names)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]SheetRole, sheetRoleCount)
+	for index, role := range sheetRoles {
+		roles[index] = SheetRole{Role: role, Sheet: sheets.names[index], ByName: sheets.byName}
+	}
+
+	return roles, nil
+}
+
+// newWorkbookSheets picks the sheet of every role by name, or the first
+// positionalSheets sheets by position when no sheet has a known name.
+func newWorkbookSheets(f *excelize.File, names SheetNames) (workbookSheets, error) {
+	sheets := workbookSheets{f: f}
+	issues := &validationErrors{}
+	roleNames := names.byRole()
+	for _, sheet := range f.GetSheetList() {
+		for index := range sheetRoles {
+			if !sheetNameMatches(sheet, *roleNames[index]) {
+				continue
+			}
+			if sheets.names[index] != "" {
+				issues.add("sheets %q and %q are both named like the %s sheet, rename one of them", sheets.names[index], sheet, sheetRoles[index])
+				continue
+			}
+			sheets.names[index] = sheet
+			sheets.byName = true
+		}
+	}
+
+	if !sheets.byName {
+		for index := range positionalSheets {
+			sheets.names[index] = f.GetSheetName(index)
+		}
+	} else if sheets.names[rosterSheet] == "" {
+		example := "Students"
+		if len(names.Students) > 0 {
+			example = names.Students[0]
+		}
+		issues.add("no students sheet found: the workbook names its sheets, so the students sheet must be named too, e.g. %q", example)
+	}
+
+	return sheets, issues.err()
+}
+
+func sheetNameMatches(sheet string, names []string) bool {
+	for _, name := range names {
+		if normalizeSheetName(sheet) == normalizeSheetName(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeSheetName(name string) string {
+	name = strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
+}
@@ -0,0 +1,111 @@
+package excel
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReadSheetRoles(t *testing.T) {
+	sheet := func(name string) testSheet {
+		return testSheet{name: name, rows: [][]string{{"Ana"}}}
+	}
+
+	tests := []struct {
+		name   string
+		sheets []testSheet
+		added  [][2]string
+		// want maps roles to their sheet; all other roles have none.
+		want    map[string]string
+		byName  bool
+		wantErr string
+	}{
+		{
+			name:   "by name in any order",
+			sheets: []testSheet{sheet("Notes"), sheet("izključitve"), sheet("Roster"), sheet("prefer_together")},
+			want:   map[string]string{"students": "Roster", "exclusions": "izključitve", "soft-inclusions": "prefer_together"},
+			byName: true,
+		},
+		{
+			name:   "by name added for a role",
+			sheets: []testSheet{sheet("Class 3A"), sheet("Apart")},
+			added:  [][2]string{{"students", "Class 3A"}, {"exclusions", "Apart"}},
+			want:   map[string]string{"students": "Class 3A", "exclusions": "Apart"},
+			byName: true,
+		},
+		{
+			name:   "by position only for the first three sheets",
+			sheets: []testSheet{sheet("Sheet1"), sheet("Sheet2"), sheet("Sheet3"), sheet("Notes"), sheet("Scratch")},
+			want:   map[string]string{"students": "Sheet1", "exclusions": "Sheet2", "inclusions": "Sheet3"},
+		},
+		{
+			name:    "two sheets for one role",
+			sheets:  []testSheet{sheet("Students"), sheet("Roster")},
+			wantErr: `sheets "Students" and "Roster" are both named like the students sheet`,
+		},
+		{
+			name:    "named sheets without a students sheet",
+			sheets:  []testSheet{sheet("Data"), sheet("Exclusions")},
+			wantErr: "no students sheet found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names := DefaultSheetNames()
+			for _, added := range test.added {
+				if err := names.Add(added[0], added[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			roles, err := ReadSheetRoles(newWorkbook(t, test.sheets...), names)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("got error %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(roles) != len(sheetRoles) {
+				t.Fatalf("%d roles, want %d", len(roles), len(sheetRoles))
+			}
+			for _, role := range roles {
+				if role.Sheet != test.want[role.Role] {
+					t.Errorf("%s sheet is %q, want %q", role.Role, role.Sheet, test.want[role.Role])
+				}
+				if role.ByName != test.byName {
+					t.Errorf("%s sheet found by name %t, want %t", role.Role, role.ByName, test.byName)
+				}
+			}
+		})
+	}
+}
+
+func TestPositionalWorkbookIgnoresExtraSheets(t *testing.T) {
+	// A notes sheet after the first three is not read as soft exclusions,
+	// which would fail on the names it holds.
+	filename := newWorkbook(t,
+		testSheet{name: "Sheet1", rows: [][]string{{"Ana"}, {"Bor"}, {"Cene"}}},
+		testSheet{name: "Sheet2", rows: [][]string{{"Ana"}, {"Bor"}}},
+		testSheet{name: "Sheet3"},
+		testSheet{name: "Notes", rows: [][]string{{"Remember to ask Dana"}}},
+	)
+
+	data, err := ReadExcelNumGroups(filename, DefaultInputLayout())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(data.Students, []string{"Ana", "Bor", "Cene"}) {
+		t.Errorf("students %v, want [Ana Bor Cene]", data.Students)
+	}
+	if len(data.Exclusions) != 1 || !slices.Equal(data.Exclusions[0], []string{"Ana", "Bor"}) {
+		t.Errorf("exclusions %v, want [[Ana Bor]]", data.Exclusions)
+	}
+	if len(data.SoftExclusions) != 0 {
+		t.Errorf("soft exclusions %v read from the notes sheet", data.SoftExclusions)
+	}
+}
@@ -0,0 +1,130 @@
+package excel
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// sheetSource gives the readers access to the sheets of the input by role,
+// so that workbooks and sets of CSV files share one set of validation rules.
+// Sheets are indexed by role: rosterSheet, exclusionsSheet and so on.
+type sheetSource interface {
+	hasSheet(index int) bool
+	getRows(index int) ([][]string, error)
+	getCols(index int) ([][]string, error)
+	// cellName returns how validation errors refer to a cell, e.g. "B3".
+	cellName(sheetIndex int, colIndex int, rowIndex int) string
+	// sheetName returns the name of the sheet, or "" if it has none.
+	sheetName(index int) string
+}
+
+// sheetError reports an error reading a sheet. Invalid input, such as names
+// split over two cells with spaces around them, is reported as it is.
+func sheetError(err error) error {
+	if errors.Is(err, ErrInvalidInput) {
+		return err
+	}
+
+	return fmt.Errorf("%s %s\n%s", errParsingExcelFile, err, errNotifyDeveloper)
+}
+
+// cellReference returns a reference to a cell that names its sheet, e.g.
+// "Exclusions!B3".
+func cellReference(f sheetSource, sheetIndex int, colIndex int, rowIndex int) string {
+	cell := f.cellName(sheetIndex, colIndex, rowIndex)
+	name := f.sheetName(sheetIndex)
+	if name == "" {
+		return cell
+	}
+	if strings.ContainsAny(name, " '!-") {
+		name = "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	}
+
+	return name + "!" + cell
+}
+
+// workbookSheets reads the sheets of an Excel workbook, found by
+// newWorkbookSheets.
+type workbookSheets struct {
+	f *excelize.File
+	// names holds the name of the sheet of every role, or "" for a role
+	// without a sheet.
+	names [sheetRoleCount]string
+	// byName is true when the sheets were recognized by name rather than
+	// position.
+	byName bool
+}
+
+func (w workbookSheets) hasSheet(index int) bool {
+	return w.names[index] != ""
+}
+
+func (w workbookSheets) getRows(index int) ([][]string, error) {
+	return w.f.GetRows(w.names[index])
+}
+
+func (w workbookSheets) getCols(index int) ([][]string, error) {
+	return w.f.GetCols(w.names[index])
+}
+
+func (w workbookSheets) cellName(_ int, colIndex int, rowIndex int) string {
+	return spreadsheetCell(colIndex, rowIndex)
+}
+
+func (w workbookSheets) sheetName(index int) string {
+	return w.names[index]
+}
+
+// tableSheets holds rows read from outside a workbook, such as CSV files, at
+// the index of the role of the sheet they replace. A nil entry is a missing
+// sheet.
+type tableSheets struct {
+	sheets [][][]string
+	// names holds the name of every sheet, if it has one.
+	names []string
+}
+
+func (t tableSheets) hasSheet(index int) bool {
+	return index < len(t.sheets) && t.sheets[index] != nil
+}
+
+func (t tableSheets) getRows(index int) ([][]string, error) {
+	return t.sheets[index], nil
+}
+
+// getCols transposes the rows, leaving out trailing empty cells like
+// excelize does for workbook columns.
+func (t tableSheets) getCols(index int) ([][]string, error) {
+	rows := t.sheets[index]
+	cols := make([][]string, maxColumnCount(rows))
+	for colIndex := range cols {
+		column := make([]string, len(rows))
+		length := 0
+		for rowIndex, row := range rows {
+			if colIndex < len(row) {
+				column[rowIndex] = row[colIndex]
+			}
+			if column[rowIndex] != "" {
+				length = rowIndex + 1
+			}
+		}
+		cols[colIndex] = column[:length]
+	}
+
+	return cols, nil
+}
+
+func (t tableSheets) cellName(_ int, colIndex int, rowIndex int) string {
+	return spreadsheetCell(colIndex, rowIndex)
+}
+
+func (t tableSheets) sheetName(index int) string {
+	if index < len(t.names) {
+		return t.names[index]
+	}
+
+	return ""
+}
@@ -0,0 +1,224 @@
+package excel
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/types"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// writeSummarySheet lists the run settings, the penalty of every option when
+// there are several, the size of every group, how many students got which of
+// their choices and the preferences and wishes that were not met.
+func writeSummarySheet(f *excelize.File, options []Option, summary Summary) error {
+	if _, err := f.NewSheet(summarySheetName); err != nil {
+		return err
+	}
+
+	rowIndex := 0
+	setting := func(name string, value any) {
+		f.SetCellValue(summarySheetName, spreadsheetCell(0, rowIndex), name)
+		f.SetCellValue(summarySheetName, spreadsheetCell(1, rowIndex), value)
+		rowIndex++
+	}
+
+	switch {
+	case summary.Mode == grouping.ByCount && len(summary.GroupNames) > 0:
+		setting("Mode", "Named groups ("+strconv.Itoa(summary.NumGroups)+")")
+	case summary.Mode == grouping.ByCount:
+		setting("Mode", "Number of groups ("+strconv.Itoa(summary.NumGroups)+")")
+	case summary.Mode == grouping.ByPreference:
+		setting("Mode", "Ranked choices ("+strconv.Itoa(summary.NumGroups)+")")
+	default:
+		setting("Mode", "Subject groups")
+	}
+	// Stored as text, since large seeds do not fit into a spreadsheet number.
+	setting("Seed", strconv.FormatInt(summary.Seed, 10))
+	if summary.InputFile != "" {
+		setting("Input file", summary.InputFile)
+	}
+	if !summary.Created.IsZero() {
+		setting("Created", summary.Created.Format("2006-01-02 15:04:05"))
+	}
+
+	groups := options[0].Groups
+	students := 0
+	for _, group := range groups {
+		students += len(group)
+	}
+	setting("Students", students)
+	setting("Groups", len(groups))
+
+	// Options, best first
+	if len(options) > 1 {
+		rowIndex++
+		f.SetCellValue(summarySheetName, spreadsheetCell(0, rowIndex), optionHeader)
+		f.SetCellValue(summarySheetName, spreadsheetCell(1, rowIndex), penaltyHeader)
+		rowIndex++
+		for optionIndex, option := range options {
+			setting(optionSheetName(optionIndex), option.Penalty)
+		}
+	}
+
+	// Group sizes
+	rowIndex++
+	f.SetCellValue(summarySheetName, spreadsheetCell(0, rowIndex), groupHeader)
+	f.SetCellValue(summarySheetName, spreadsheetCell(1, rowIndex), sizeHeader)
+	rowIndex++
+	names := outputGroupNames(summary.GroupNames, len(groups))
+	for i, group := range groups {
+		setting(names[i], len(group))
+	}
+
+	if satisfaction := summary.Satisfaction; satisfaction != nil {
+		rowIndex++
+		for place, count := range satisfaction.Choices {
+			setting(grouping.ChoiceLabel(place+1), count)
+		}
+		setting("Not ranked", satisfaction.Unranked)
+	}
+
+	rowIndex++
+	setting("Violated preferences", len(summary.ViolatedPreferences))
+	for _, violation := range summary.ViolatedPreferences {
+		f.SetCellValue(summarySheetName, spreadsheetCell(1, rowIndex), violation)
+		rowIndex++
+	}
+
+	if summary.UnmetWishes != nil {
+		rowIndex++
+		setting("Unmet wishes", len(summary.UnmetWishes))
+		for _, wish := range summary.UnmetWishes {
+			f.SetCellValue(summarySheetName, spreadsheetCell(1, rowIndex), wish.String())
+			rowIndex++
+		}
+	}
+
+	return nil
+}
+
+// writeConstraintCheckSheet lists every exclusion and inclusion group of the
+// input, hard and soft, every fixed assignment and every nomination, with the
+// groups its students ended up in, by number or by name, and whether it was
+// met.
+func writeConstraintCheckSheet(f *excelize.File, groups [][]string, names []string, data *types.GroupingData) error {
+	if _, err := f.NewSheet(constraintsSheetName); err != nil {
+		return err
+	}
+
+	groupOf := make(map[string]int)
+	for i, group := range groups {
+		for _, student := range group {
+			groupOf[student] = i
+		}
+	}
+
+	label := func(group int) string {
+		if len(names) > 0 {
+			return outputGroupNames(names, group+1)[group]
+		}
+		return strconv.Itoa(group + 1)
+	}
+
+	f.SetCellValue(constraintsSheetName, "A1", "Constraint")
+	f.SetCellValue(constraintsSheetName, "B1", "Students")
+	f.SetCellValue(constraintsSheetName, "C1", "Groups")
+	f.SetCellValue(constraintsSheetName, "D1", "Status")
+	rowIndex := 1
+	check := func(name string, constraintGroups [][]string, together bool, hard bool) {
+		for i, constraintGroup := range constraintGroups {
+			met := true
+			seen := make(map[int]bool)
+			groupNames := make([]string, 0, len(constraintGroup))
+			for _, student := range constraintGroup {
+				group, placed := groupOf[student]
+				if !placed {
+					groupNames = append(groupNames, "-")
+					continue
+				}
+				groupNames = append(groupNames, label(group))
+				if !together && seen[group] {
+					met = false
+				}
+				seen[group] = true
+			}
+			if together && len(seen) > 1 {
+				met = false
+			}
+
+			status := "Satisfied"
+			switch {
+			case !met && hard:
+				status = "Violated"
+			case !met:
+				status = "Not met"
+			}
+
+			f.SetCellValue(constraintsSheetName, spreadsheetCell(0, rowIndex), name+" "+strconv.Itoa(i+1))
+			f.SetCellValue(constraintsSheetName, spreadsheetCell(1, rowIndex), strings.Join(constraintGroup, ", "))
+			f.SetCellValue(constraintsSheetName, spreadsheetCell(2, rowIndex), strings.Join(groupNames, ", "))
+			f.SetCellValue(constraintsSheetName, spreadsheetCell(3, rowIndex), status)
+			rowIndex++
+		}
+	}
+	check("Exclusion", data.Exclusions, false, true)
+	check("Inclusion", data.Inclusions, true, true)
+	check("Prefer apart", data.SoftExclusions, false, false)
+	check("Prefer together", data.SoftInclusions, true, false)
+
+	for _, student := range sortedKeys(data.Assignments) {
+		fixedGroup := data.Assignments[student]
+		placedGroup, status := "-", "Violated"
+		if group, placed := groupOf[student]; placed {
+			placedGroup = label(group)
+			if group == fixedGroup {
+				status = "Satisfied"
+			}
+		}
+
+		constraint := "Fixed to group " + label(fixedGroup)
+		if len(names) > 0 {
+			constraint = "Fixed to " + label(fixedGroup)
+		}
+
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(0, rowIndex), constraint)
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(1, rowIndex), student)
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(2, rowIndex), placedGroup)
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(3, rowIndex), status)
+		rowIndex++
+	}
+
+	// A nomination is met when the student shares a group with any of the
+	// classmates they nominated.
+	for _, nomination := range data.Nominations {
+		if len(nomination) < 2 {
+			continue
+		}
+
+		group, studentPlaced := groupOf[nomination[0]]
+		status := "Not met"
+		groupNames := make([]string, 0, len(nomination))
+		for i, student := range nomination {
+			wishGroup, placed := groupOf[student]
+			if !placed {
+				groupNames = append(groupNames, "-")
+				continue
+			}
+			groupNames = append(groupNames, label(wishGroup))
+			if i > 0 && studentPlaced && wishGroup == group {
+				status = "Satisfied"
+			}
+		}
+
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(0, rowIndex), "Wishes of "+nomination[0])
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(1, rowIndex), strings.Join(nomination, ", "))
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(2, rowIndex), strings.Join(groupNames, ", "))
+		f.SetCellValue(constraintsSheetName, spreadsheetCell(3, rowIndex), status)
+		rowIndex++
+	}
+
+	return nil
+}
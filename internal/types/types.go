@@ -1,8 +0,0 @@
-package types
-
-type GroupingData struct {
-	SubjectStudents map[string][]string
-	Students        []string
-	Exclusions      [][]string
-	Inclusions      [][]string
-}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/internal/excel"
+	"github.com/kremec/edugroup/types"
+)
+
+// commandServe runs the HTTP API server.
+const commandServe = "serve"
+
+// indexPage is the browser UI, a single page without external assets that
+// uses the API.
+//
+//go:embed web/index.html
+var indexPage []byte
+
+// maxUploadSize limits the size of uploaded workbooks and JSON problems.
+const maxUploadSize = 10 << 20
+
+// maxAlternatives limits the groupings a request can ask for. SolveAlternatives
+// makes up to eight attempts at each of them, all within the time budget of
+// the request.
+const maxAlternatives = 10
+
+const excelContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// runServe serves the grouping API on opts.addr until the server fails and
+// returns the process exit code.
+func runServe(opts cliOptions) int {
+	if opts.inputFile != "" || opts.outputFile != "" {
+		return usageError("-in and -out cannot be used with serve")
+	}
+	if flag.NArg() > 0 {
+		return usageError("unexpected arguments: %s", strings.Join(flag.Args(), " "))
+	}
+
+	var err error
+	opts.inputLayout, err = loadInputLayout(opts.inputLayoutPath, opts.sheetNames)
+	if err != nil {
+		return inputError(err)
+	}
+	// The history is read once and shared by all requests, which only read it.
+	opts.history, err = loadHistory(opts.historyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	server := &http.Server{
+		Addr:              opts.addr,
+		Handler:           newServeMux(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	fmt.Printf("Open http://%s/ in a browser to make groups, the API is at /api/group\n", browserAddr(opts.addr))
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitIO
+	}
+
+	return exitOK
+}
+
+func newServeMux(opts cliOptions) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexPage)
+	})
+	mux.HandleFunc("POST /api/group", func(w http.ResponseWriter, r *http.Request) {
+		serveGroup(w, r, opts)
+	})
+
+	return mux
+}
+
+// apiError is the body of every error response. Issues lists the problems
+// of invalid input and Conflicts the constraints that cannot all be met.
+type apiError struct {
+	Error     string   `json:"error"`
+	Issues    []string `json:"issues,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// serveGroup groups the students of a JSON problem or an uploaded workbook
+// and responds with the result in JSON or as a workbook.
+//
+// A JSON problem is sent as the request body with Content-Type
+// application/json and is answered in JSON. A workbook is uploaded as the
+// "file" field of a multipart form, with the fields "mode", "groups",
+// "seed", "balance", "min-size", "max-size", "alternatives" and
+// "min-difference" like the command-line flags;
+// it is answered with the output workbook in the "layout" given, or in JSON
+// when the "format" field is "json".
+func serveGroup(w http.ResponseWriter, r *http.Request, cli cliOptions) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	var data *types.GroupingData
+	var opts grouping.Options
+	var err error
+	inputName := "groups"
+	format := "json"
+	layout := cli.layout
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		// The problem is read first, so that a too large one is not reported
+		// as invalid JSON.
+		problem, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		data, opts, err = excel.DecodeJSONProblem(bytes.NewReader(problem))
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if opts.Weights == nil {
+			opts.Weights = &cli.weights
+		}
+		opts.History = cli.history
+
+	case "multipart/form-data":
+		file, header, err := r.FormFile("file")
+		if tooLarge := new(http.MaxBytesError); errors.As(err, &tooLarge) {
+			writeAPIError(w, err)
+			return
+		}
+		if err != nil {
+			writeAPIError(w, requestError("the workbook must be uploaded as the \"file\" field: %s", err))
+			return
+		}
+		defer file.Close()
+		inputName = header.Filename
+
+		opts, format, layout, err = formOptions(r, cli)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if opts.Mode == grouping.BySubject {
+			data, err = excel.DecodeExcelSubjectGroups(file, cli.inputLayout)
+		} else {
+			data, err = excel.DecodeExcelNumGroups(file, cli.inputLayout)
+		}
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+	default:
+		writeJSON(w, http.StatusUnsupportedMediaType, apiError{Error: "send a JSON problem as application/json or a workbook as multipart/form-data"})
+		return
+	}
+
+	switch named := len(data.NamedGroups); {
+	case opts.Mode == grouping.BySubject:
+	case opts.Mode == grouping.ByPreference && named == 0:
+		writeAPIError(w, &excel.InputError{Issues: []string{"preference mode needs named groups for the students to rank"}})
+		return
+	case opts.NumGroups <= 0 && named == 0:
+		writeAPIError(w, &excel.InputError{Issues: []string{"the number of groups or named groups must be given in count mode"}})
+		return
+	case named > 0 && opts.NumGroups != 0 && opts.NumGroups != named:
+		writeAPIError(w, &excel.InputError{Issues: []string{fmt.Sprintf("%d groups were asked for, but the input names %d groups", opts.NumGroups, named)}})
+		return
+	}
+	if opts.Alternatives > maxAlternatives {
+		writeAPIError(w, requestError("at most %d alternatives can be asked for, not %d", maxAlternatives, opts.Alternatives))
+		return
+	}
+	// Requests cannot search for longer than the server allows.
+	if opts.TimeBudget <= 0 || opts.TimeBudget > cli.timeBudget {
+		opts.TimeBudget = cli.timeBudget
+	}
+
+	results, err := grouping.SolveAlternatives(r.Context(), data, opts)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	var body bytes.Buffer
+	contentType := "application/json"
+	if format == "xlsx" {
+		contentType = excelContentType
+		summary := exportSummary(results[0], data, opts.Mode, opts.NumGroups, inputName)
+		err = excel.EncodeExcel(&body, exportOptions(results), summary, layout)
+		name := strings.TrimSuffix(filepath.Base(inputName), filepath.Ext(inputName)) + "_groups.xlsx"
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	} else {
+		err = excel.EncodeJSONResult(&body, results)
+	}
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body.Bytes())
+}
+
+// formOptions reads the grouping options, the output format and the layout
+// of an uploaded workbook from the form fields of r.
+func formOptions(r *http.Request, cli cliOptions) (grouping.Options, string, excel.Layout, error) {
+	issues := make([]string, 0)
+	intField := func(name string, fallback int) int {
+		value := strings.TrimSpace(r.FormValue(name))
+		if value == "" {
+			return fallback
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			issues = append(issues, fmt.Sprintf("%s %q must be a non-negative whole number", name, value))
+		}
+		return number
+	}
+
+	numGroups := intField("groups", 0)
+	mode := grouping.BySubject
+	switch r.FormValue("mode") {
+	case "":
+		if numGroups > 0 {
+			mode = grouping.ByCount
+		}
+	case modeSubject:
+	case modeCount:
+		mode = grouping.ByCount
+	case modePreference:
+		mode = grouping.ByPreference
+	default:
+		issues = append(issues, fmt.Sprintf("mode %q must be %q, %q or %q", r.FormValue("mode"), modeSubject, modeCount, modePreference))
+	}
+	if mode != grouping.ByCount && numGroups != 0 {
+		issues = append(issues, "groups can only be given in count mode")
+	}
+
+	opts := groupingOptions(mode, numGroups, cli)
+	opts.MinSize = intField("min-size", cli.minSize)
+	opts.MaxSize = intField("max-size", cli.maxSize)
+	if opts.MaxSize > 0 && opts.MinSize > opts.MaxSize {
+		issues = append(issues, fmt.Sprintf("min-size %d must not be larger than max-size %d", opts.MinSize, opts.MaxSize))
+	}
+	opts.Alternatives = intField("alternatives", cli.alternatives)
+	opts.MinDifference = intField("min-difference", cli.minDifference)
+	if value := strings.TrimSpace(r.FormValue("seed")); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seed < 0 {
+			issues = append(issues, fmt.Sprintf("seed %q must be a non-negative whole number", value))
+		}
+		opts.Seed = seed
+	}
+	if value := strings.TrimSpace(r.FormValue("balance")); value != "" {
+		balance, err := strconv.ParseBool(value)
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("balance %q must be true or false", value))
+		}
+		opts.Balance = balance
+	}
+	format := "xlsx"
+	switch r.FormValue("format") {
+	case "", "xlsx":
+	case "json":
+		format = "json"
+	default:
+		issues = append(issues, fmt.Sprintf("format %q must be \"xlsx\" or \"json\"", r.FormValue("format")))
+	}
+
+	layout := cli.layout
+	if value := r.FormValue("layout"); value != "" {
+		var err error
+		if layout, err = excel.ParseLayout(value); err != nil {
+			issues = append(issues, err.Error())
+		}
+	}
+
+	if len(issues) > 0 {
+		return opts, format, layout, &requestIssues{issues: issues}
+	}
+	return opts, format, layout, nil
+}
+
+// requestIssues are problems with the request itself rather than with the
+// uploaded data.
+type requestIssues struct {
+	issues []string
+}
+
+func (e *requestIssues) Error() string {
+	return "invalid request:\n- " + strings.Join(e.issues, "\n- ")
+}
+
+func requestError(format string, args ...any) error {
+	return &requestIssues{issues: []string{fmt.Sprintf(format, args...)}}
+}
+
+// writeAPIError responds with the status that matches err: 400 for a bad
+// request, 413 for a too large upload, 422 for invalid input, including
+// options the grouping engine rejects, or input that cannot be grouped, and
+// 500 otherwise.
+func writeAPIError(w http.ResponseWriter, err error) {
+	var request *requestIssues
+	var tooLarge *http.MaxBytesError
+	var input *excel.InputError
+	var infeasible *grouping.InfeasibleError
+	switch {
+	case errors.As(err, &request):
+		writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid request", Issues: request.issues})
+	case errors.As(err, &tooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, apiError{Error: fmt.Sprintf("uploads are limited to %d MB", maxUploadSize>>20)})
+	case errors.As(err, &input):
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "invalid input", Issues: input.Issues})
+	case errors.Is(err, grouping.ErrInvalidInput):
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: "invalid input", Issues: []string{err.Error()}})
+	case errors.As(err, &infeasible):
+		conflicts := make([]string, 0, len(infeasible.Conflict))
+		for _, constraint := range infeasible.Conflict {
+			conflicts = append(conflicts, constraint.String())
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: infeasible.Msg, Conflicts: conflicts})
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// browserAddr returns the address for a browser on this computer, which
+// cannot connect to an address without a host such as ":8080".
+func browserAddr(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		return "localhost" + addr
+	}
+
+	return addr
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kremec/edugroup/grouping"
+	"github.com/kremec/edugroup/internal/excel"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	opts := cliOptions{
+		weights:      grouping.DefaultWeights(),
+		timeBudget:   time.Second,
+		alternatives: 1,
+		inputLayout:  excel.DefaultInputLayout(),
+	}
+	server := httptest.NewServer(newServeMux(opts))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// multipartBody returns a form with the workbook at path as the "file" field,
+// unless path is empty, and fields.
+func multipartBody(t *testing.T, path string, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := form.CreateFormFile("file", "students.xlsx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Write(content)
+	}
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &body, form.FormDataContentType()
+}
+
+func TestServeStatusCodes(t *testing.T) {
+	server := newTestServer(t)
+
+	noFile, noFileType := multipartBody(t, "", map[string]string{"groups": "2"})
+	badField, badFieldType := multipartBody(t, "examples/by-numgroups_in.xlsx", map[string]string{"groups": "two"})
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"JSON problem", "application/json", `{"students": ["Ana", "Bor", "Cene"], "options": {"numGroups": 2}}`, http.StatusOK},
+		{"plain text", "text/plain", "Ana, Bor", http.StatusUnsupportedMediaType},
+		{"no file field", noFileType, noFile.String(), http.StatusBadRequest},
+		{"invalid form field", badFieldType, badField.String(), http.StatusBadRequest},
+		{"too large", "application/json", `{"students": ["` + strings.Repeat("A", maxUploadSize) + `"]}`, http.StatusRequestEntityTooLarge},
+		{"invalid input", "application/json", `{"students": ["Ana", "Ana"], "options": {"numGroups": 2}}`, http.StatusUnprocessableEntity},
+		{"infeasible", "application/json", `{"students": ["Ana", "Bor", "Cene"], "exclusions": [["Ana", "Bor", "Cene"]], "options": {"numGroups": 2}}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := http.Post(server.URL+"/api/group", test.contentType, strings.NewReader(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode != test.status {
+				t.Errorf("status %d, want %d", response.StatusCode, test.status)
+			}
+			if response.StatusCode != http.StatusOK {
+				var body apiError
+				if err := json.NewDecoder(response.Body).Decode(&body); err != nil || body.Error == "" {
+					t.Errorf("error body %+v: %v", body, err)
+				}
+			}
+		})
+	}
+}
+
+func TestServeInfeasibleConflicts(t *testing.T) {
+	server := newTestServer(t)
+
+	problem := `{"students": ["Ana", "Bor", "Cene"], "exclusions": [["Ana", "Bor", "Cene"]], "options": {"numGroups": 2}}`
+	response, err := http.Post(server.URL+"/api/group", "application/json", strings.NewReader(problem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var body apiError
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Conflicts) == 0 {
+		t.Errorf("no conflicts in %+v", body)
+	}
+}
+
+func TestServeMultipartFields(t *testing.T) {
+	server := newTestServer(t)
+
+	body, contentType := multipartBody(t, "examples/by-numgroups_in.xlsx", map[string]string{"mode": "count", "groups": "3", "seed": "5", "format": "json"})
+	response, err := http.Post(server.URL+"/api/group", contentType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want %d", response.StatusCode, http.StatusOK)
+	}
+
+	var result struct {
+		Groups [][]string `json:"groups"`
+		Seed   int64      `json:"seed"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 3 || result.Seed != 5 {
+		t.Errorf("%d groups with seed %d, want 3 with seed 5", len(result.Groups), result.Seed)
+	}
+
+	body, contentType = multipartBody(t, "examples/by-numgroups_in.xlsx", map[string]string{"groups": "3", "layout": "table"})
+	response, err = http.Post(server.URL+"/api/group", contentType, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if got := response.Header.Get("Content-Type"); got != excelContentType {
+		t.Errorf("content type %q, want %q", got, excelContentType)
+	}
+	if got := response.Header.Get("Content-Disposition"); !strings.Contains(got, "students_groups.xlsx") {
+		t.Errorf("content disposition %q", got)
+	}
+}
+
+func TestServeHistory(t *testing.T) {
+	history := grouping.NewHistory()
+	history.AddRound([][]string{{"Ana", "Bor"}, {"Cene", "Dana"}})
+	opts := cliOptions{
+		weights:      grouping.DefaultWeights(),
+		timeBudget:   time.Second,
+		alternatives: 1,
+		inputLayout:  excel.DefaultInputLayout(),
+		history:      history,
+	}
+	server := httptest.NewServer(newServeMux(opts))
+	defer server.Close()
+
+	for seed := range 5 {
+		problem := `{"students": ["Ana", "Bor", "Cene", "Dana"], "options": {"numGroups": 2, "seed": ` + strconv.Itoa(seed+1) + `}}`
+		response, err := http.Post(server.URL+"/api/group", "application/json", strings.NewReader(problem))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result struct {
+			Groups [][]string `json:"groups"`
+		}
+		err = json.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, group := range result.Groups {
+			if slices.Contains(group, "Ana") && slices.Contains(group, "Bor") {
+				t.Errorf("seed %d put Ana and Bor together again: %v", seed+1, result.Groups)
+			}
+		}
+	}
+}
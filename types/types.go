@@ -0,0 +1,54 @@
+// Package types holds the data shared between the input readers and the
+// grouping engine.
+package types
+
+// GroupingData is a grouping problem as read from the input file.
+// Subjects and SubjectStudents are filled in subject-groups mode, Students in
+// number-of-groups mode. Subjects keeps the input order of the subjects; when
+// it is empty the subjects are processed in alphabetical order.
+type GroupingData struct {
+	Subjects        []string
+	SubjectStudents map[string][]string
+	Students        []string
+	Exclusions      [][]string
+	Inclusions      [][]string
+	// ExclusionCells and InclusionCells, when filled, hold the input cell
+	// every name of Exclusions and Inclusions was read from, e.g.
+	// "Exclusions!B3", so that conflicts can be traced back to the input.
+	ExclusionCells [][]string
+	InclusionCells [][]string
+	// SoftExclusions and SoftInclusions are preferences: students who should
+	// better not work together, and students who would preferably work
+	// together. Unlike Exclusions and Inclusions they may be violated.
+	SoftExclusions [][]string
+	SoftInclusions [][]string
+	// NamedGroups, when filled in number-of-groups or preference mode, are
+	// the groups to
+	// split the students into, in output order, instead of a plain number of
+	// groups.
+	NamedGroups []NamedGroup
+	// Rankings, when filled in preference mode, list the named groups every
+	// student would like to be in, as indexes into NamedGroups, best first.
+	Rankings map[string][]int
+	// Nominations lists the classmates students would like to work with, one
+	// entry per nominating student: their own name first, then their wishes.
+	Nominations [][]string
+	// Assignments fixes students to groups: every student in it is placed in
+	// the group with the given zero-based index. AssignmentCells, when
+	// filled, holds the input cell every assignment was read from.
+	Assignments     map[string]int
+	AssignmentCells map[string]string
+	// Attributes lists the names of extra roster columns, such as gender or
+	// skill level, in input order. StudentAttributes maps every student to
+	// their value of each attribute; missing values are left out.
+	Attributes        []string
+	StudentAttributes map[string]map[string]string
+}
+
+// NamedGroup is a group given by the input, such as a lab or a project
+// topic, with the most students it can take. A Capacity of zero means no
+// limit.
+type NamedGroup struct {
+	Name     string
+	Capacity int
+}